@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/bboles/glids/internal/display"
+	"github.com/bboles/glids/internal/gitlab"
+	"github.com/bboles/glids/internal/termui"
+)
+
+// runAncestorsMode prints the chain of groups from the top-level group down to
+// the project or group at target (a full path or numeric ID), optionally with
+// the siblings at each level.
+func runAncestorsMode(client *gitlab.Client, target string, siblings bool, opts runOptions, status *termui.Status) {
+	defer status.Stop()
+	debugLogger.Printf("Running in ancestors mode, target: '%s', siblings: %t", target, siblings)
+
+	// Resolve the target: projects first, since that is the common question
+	var project gitlab.Project
+	var group gitlab.Group
+	isProject := false
+	project, err := client.GetProject(target)
+	if err == nil {
+		isProject = true
+	} else if gitlab.IsNotFound(err) {
+		group, err = client.GetGroup(target)
+	}
+	if err != nil {
+		status.Stop()
+		if gitlab.IsNotFound(err) {
+			fmt.Fprintf(os.Stderr, "\nError: no project or group found at %s\n", target)
+		} else {
+			fmt.Fprintf(os.Stderr, "\nError resolving %s: %v\n", target, err)
+		}
+		exit(1)
+	}
+
+	// Walk up from the group containing the target
+	var chain []gitlab.Group
+	var targetRecord display.Record
+	if isProject {
+		targetRecord = display.ProjectRecord(project)
+		ns := project.Namespace
+		if ns.Kind == "group" {
+			group, err = client.GetGroup(strconv.Itoa(ns.ID))
+			if err == nil {
+				chain, err = client.GetAncestors(group)
+			}
+		} else {
+			// Personal projects live in a user namespace, which has no parents
+			chain = []gitlab.Group{{ID: ns.ID, FullPath: ns.FullPath, Name: ns.Name}}
+		}
+	} else {
+		targetRecord = display.GroupRecord(group)
+		chain, err = client.GetAncestors(group)
+	}
+	if err != nil {
+		status.Stop()
+		exitOnFetchError(err, "ancestors")
+	}
+
+	root, err := buildAncestorTree(client, chain, project, isProject, siblings, opts.allItems)
+	status.Stop()
+	exitOnFetchError(err, "siblings")
+
+	if opts.format == display.FormatText {
+		encodeOrExit(display.FprintAncestors(opts.out, root, targetRecord, opts.printing...))
+		return
+	}
+	enc := newStdoutEncoder(opts)
+	encodeOrExit(enc.Tree(root))
+	encodeOrExit(enc.Close())
+}
+
+// buildAncestorTree nests chain (top-level group first) into a single
+// branch ending at the target, adding each level's other subgroups and
+// projects when siblings is set.
+func buildAncestorTree(client *gitlab.Client, chain []gitlab.Group, project gitlab.Project, isProject, siblings bool, allItems bool) (gitlab.Group, error) {
+	// A personal project's user namespace has no group listings to show siblings from
+	userNamespace := isProject && project.Namespace.Kind != "group"
+
+	var child *gitlab.Group // The branch built so far, below the current level
+	for i := len(chain) - 1; i >= 0; i-- {
+		g := chain[i]
+		g.Subgroups, g.Projects = nil, nil
+		isLast := i == len(chain)-1
+
+		if siblings && !userNamespace && (!isLast || isProject) {
+			subgroups, err := client.GetSubgroups(g.ID, allItems)
+			if err != nil {
+				return gitlab.Group{}, err
+			}
+			projects, err := client.GetGroupProjects(g.ID, allItems)
+			if err != nil {
+				return gitlab.Group{}, err
+			}
+			g.Subgroups, g.Projects = subgroups, projects
+		}
+
+		// Make sure the branch itself is present (the activity filter may hide it)
+		if child != nil {
+			replaced := false
+			for j := range g.Subgroups {
+				if g.Subgroups[j].ID == child.ID {
+					g.Subgroups[j] = *child
+					replaced = true
+				}
+			}
+			if !replaced {
+				g.Subgroups = append(g.Subgroups, *child)
+			}
+		}
+		if isLast && isProject && !containsProject(g.Projects, project.ID) {
+			g.Projects = append(g.Projects, project)
+		}
+
+		gitlab.SortGroups(g.Subgroups, gitlab.SortName, false)
+		gitlab.SortProjects(g.Projects, gitlab.SortName, false)
+		child = &g
+	}
+	if child == nil {
+		return gitlab.Group{}, errors.New("empty ancestor chain")
+	}
+	return *child, nil
+}
+
+// containsProject reports whether projects includes the project with id.
+func containsProject(projects []gitlab.Project, id int) bool {
+	for _, p := range projects {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bboles/glids/internal/gitlab"
+	"github.com/bboles/glids/internal/termui"
+)
+
+// runBrowseMode opens a lazy tree browser rooted at the matching groups and
+// prints the ID or path of the chosen node on stdout.
+func runBrowseMode(client *gitlab.Client, opts runOptions, status *termui.Status) {
+	debugLogger.Printf("Running in browse mode, search term: '%s'", opts.searchTerm)
+
+	roots, err := gitlab.Collect(client.Groups(opts.searchTerm, opts.allItems), opts.limit)
+	status.Stop()
+	exitOnFetchError(err, "groups")
+	if len(roots) == 0 {
+		fmt.Fprintln(os.Stderr, "No groups found matching search term:", opts.searchTerm)
+		exit(1)
+	}
+	gitlab.SortGroups(roots, gitlab.SortPath, false)
+
+	browser := &termui.Browser{
+		Roots: roots,
+		Children: func(g gitlab.Group) ([]gitlab.Group, []gitlab.Project, error) {
+			subgroups, err := client.GetSubgroups(g.ID, opts.allItems)
+			if err != nil {
+				return nil, nil, err
+			}
+			projects, err := client.GetGroupProjects(g.ID, opts.allItems)
+			if err != nil {
+				return subgroups, nil, err
+			}
+			gitlab.SortGroups(subgroups, gitlab.SortName, false)
+			gitlab.SortProjects(projects, gitlab.SortName, false)
+			return subgroups, projects, nil
+		},
+		Counts: func(g gitlab.Group) (int, int, error) {
+			subgroups, err := client.CountSubgroups(g.ID, opts.allItems)
+			if err != nil {
+				return 0, 0, err
+			}
+			projects, err := client.CountGroupProjects(g.ID, opts.allItems)
+			return subgroups, projects, err
+		},
+	}
+	// Large listings are confirmed inside the browser rather than on a raw terminal
+	client.SetConfirmationFunction(browser.Confirm)
+	choice, err := browser.Run()
+	client.SetConfirmationFunction(termui.Confirm)
+	if errors.Is(err, termui.ErrAborted) {
+		exit(130)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		exit(1)
+	}
+	fmt.Fprintln(opts.out, choice)
+}
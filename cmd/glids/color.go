@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// useColor decides whether text output to out is coloured, for the
+// --color mode: "always", "never", or "auto", which colours only a
+// terminal and honours NO_COLOR (https://no-color.org) and TERM=dumb.
+func useColor(mode string, out io.Writer) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+	default:
+		return false, fmt.Errorf("invalid --color %q (want auto, always or never)", mode)
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false, nil
+	}
+	f, ok := out.(*os.File) // Output files are never a terminal
+	return ok && term.IsTerminal(int(f.Fd())), nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/token"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/bboles/glids/internal/display"
+)
+
+// runGen implements "glids gen": it writes a source file of constants for
+// the IDs of the selected groups and projects, for use with go:generate and
+// similar tools.
+func runGen(args []string) {
+	fs := flag.NewFlagSet(executableName+" gen", flag.ExitOnError)
+	langFlag := fs.String("lang", "go", "Language of the generated file: go, typescript or python")
+	pkg := fs.String("package", "gitlabids", "With --lang go, the package name")
+	out := fs.String("out", "", "Write to this file instead of stdout")
+	force := fs.Bool("force", false, "With --out, overwrite an existing file")
+	sel := addSelectFlags(fs)
+	conn := addConnFlags(fs, 0)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s gen [flags] [search]\n\n", executableName)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	lang, err := display.ParseLang(*langFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(2)
+	}
+	if lang == display.LangGo && !token.IsIdentifier(*pkg) {
+		fmt.Fprintf(os.Stderr, "Error: invalid Go package name %q\n", *pkg)
+		os.Exit(2)
+	}
+
+	// Refuse to clobber before spending time on the fetch
+	var file *outputFile
+	if *out != "" {
+		var err error
+		if file, err = createOutputFile(*out, *force); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	}
+
+	client, status := conn.setup()
+	defer status.Stop()
+	if !isDebug {
+		status.Start("Fetching groups and projects...")
+	}
+	inv, err := fetchInventory(client, sel.selection(fs), status)
+	status.Stop()
+	exitOnFetchError(err, "groups and projects")
+
+	host := client.BaseURL()
+	if u, err := url.Parse(host); err == nil {
+		host = u.Host
+	}
+	var buf bytes.Buffer
+	err = display.WriteConstants(&buf, display.Constants{
+		Lang:        lang,
+		Package:     *pkg,
+		Host:        host,
+		GeneratedAt: time.Now(),
+		Groups:      inv.groups,
+		Projects:    inv.projects,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		exit(1)
+	}
+
+	if file == nil {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	_, err = file.Write(buf.Bytes())
+	if err == nil {
+		err = file.commit()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d group and %d project IDs to %s\n", len(inv.groups), len(inv.projects), *out)
+}
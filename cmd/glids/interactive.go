@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/template"
+
+	"github.com/bboles/glids/internal/gitlab"
+	"github.com/bboles/glids/internal/termui"
+)
+
+// runInteractiveMode fetches matching groups and projects, lets the user pick
+// one in a full-screen fuzzy finder and prints its ID (or tmpl applied to it)
+// on stdout, so it composes like PID=$(glids -i api).
+func runInteractiveMode(client *gitlab.Client, opts runOptions, status *termui.Status, tmpl *template.Template) {
+	debugLogger.Printf("Running in interactive mode, search term: '%s'", opts.searchTerm)
+
+	groups, err := gitlab.Collect(client.Groups(opts.searchTerm, opts.allItems), opts.limit)
+	if err != nil {
+		status.Stop()
+		exitOnFetchError(err, "groups")
+	}
+	projects, err := gitlab.Collect(listProjects(client, opts), opts.limit)
+	status.Stop()
+	exitOnFetchError(err, "projects")
+
+	if len(groups) == 0 && len(projects) == 0 {
+		fmt.Fprintln(os.Stderr, "No groups or projects found matching search term:", opts.searchTerm)
+		exit(1)
+	}
+
+	picker := &termui.Picker{
+		Groups:   groups,
+		Projects: projects,
+		Expand: func(g gitlab.Group) (gitlab.Group, error) {
+			subgroups, err := client.GetSubgroups(g.ID, opts.allItems)
+			if err != nil {
+				return g, err
+			}
+			projects, err := client.GetGroupProjects(g.ID, opts.allItems)
+			if err != nil {
+				return g, err
+			}
+			gitlab.SortGroups(subgroups, gitlab.SortName, false)
+			gitlab.SortProjects(projects, gitlab.SortName, false)
+			g.Subgroups, g.Projects = subgroups, projects
+			return g, nil
+		},
+	}
+	// Large expansions are confirmed inside the picker rather than on a raw terminal
+	client.SetConfirmationFunction(picker.Confirm)
+	choice, err := picker.Run()
+	client.SetConfirmationFunction(termui.Confirm)
+	if errors.Is(err, termui.ErrAborted) {
+		exit(130) // Same as fzf when nothing was chosen
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		exit(1)
+	}
+
+	if tmpl == nil {
+		fmt.Fprintln(opts.out, choice.ID)
+		return
+	}
+	if err := tmpl.Execute(opts.out, choice); err != nil {
+		fmt.Fprintln(os.Stderr, "Error applying template:", err)
+		exit(1)
+	}
+	fmt.Fprintln(opts.out)
+}
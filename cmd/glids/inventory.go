@@ -0,0 +1,241 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/bboles/glids/internal/gitlab"
+	"github.com/bboles/glids/internal/termui"
+)
+
+// selectFlags are the flags a subcommand uses to choose groups and projects,
+// with the same meaning as for the default listing command.
+type selectFlags struct {
+	search     *string
+	all        *bool
+	groups     *bool
+	projects   *bool
+	hierarchy  *bool
+	depth      *int
+	groupsOnly *bool
+	pruneEmpty *bool
+	filter     *string
+	limit      *int
+}
+
+// addSelectFlags registers the selection flags on fs.
+func addSelectFlags(fs *flag.FlagSet) selectFlags {
+	return selectFlags{
+		search:     fs.String("search", "", "Search term to filter projects or groups"),
+		all:        fs.Bool("all", false, "Include projects/groups regardless of activity date"),
+		groups:     fs.Bool("groups", false, "Include groups only (default is both)"),
+		projects:   fs.Bool("projects", false, "Include projects only (default is both)"),
+		hierarchy:  fs.Bool("hierarchy", false, "Include every subgroup and project beneath the matching groups"),
+		depth:      fs.Int("depth", 0, "With --hierarchy, stop after this many levels below each matching group (0 for no limit)"),
+		groupsOnly: fs.Bool("groups-only", false, "With --hierarchy, include subgroups but no projects"),
+		pruneEmpty: fs.Bool("prune-empty", false, "With --hierarchy, drop groups with no projects beneath them"),
+		filter:     fs.String("filter", "", "With --hierarchy, keep only projects whose name contains this (subgroups with --groups-only), plus their ancestors"),
+		limit:      fs.Int("limit", 0, "Stop after this many matching groups/projects (0 for no limit)"),
+	}
+}
+
+// selection describes which groups and projects to fetch.
+type selection struct {
+	runOptions
+	groups   bool // Groups only
+	projects bool // Projects only
+	trees    bool // Populate the trees of matching groups
+	matches  bool // With trees, list the matching groups and projects rather than the trees' contents
+}
+
+// selection returns the parsed flags. The first positional argument of fs,
+// if any, overrides --search.
+func (sf selectFlags) selection(fs *flag.FlagSet) selection {
+	searchTerm := *sf.search
+	if fs.NArg() > 0 {
+		searchTerm = fs.Arg(0)
+	}
+	return selection{
+		runOptions: runOptions{
+			searchTerm: searchTerm,
+			allItems:   *sf.all,
+			limit:      *sf.limit,
+			hierarchy: gitlab.HierarchyOptions{
+				AllItems:   *sf.all,
+				MaxDepth:   *sf.depth,
+				GroupsOnly: *sf.groupsOnly,
+			},
+			pruneEmpty: *sf.pruneEmpty,
+			treeFilter: *sf.filter,
+		},
+		groups:   *sf.groups,
+		projects: *sf.projects,
+		trees:    *sf.hierarchy,
+	}
+}
+
+// describe lists the selection's filters in human-readable form.
+func (sel selection) describe() []string {
+	var filters []string
+	if sel.searchTerm != "" {
+		filters = append(filters, "Search: "+sel.searchTerm)
+	}
+	if !sel.allItems {
+		filters = append(filters, "Active in the last 30 days")
+	}
+	switch {
+	case sel.trees:
+		filters = append(filters, "Hierarchy of matching groups")
+	case sel.groups:
+		filters = append(filters, "Groups only")
+	case sel.projects:
+		filters = append(filters, "Projects only")
+	}
+	if sel.trees && sel.hierarchy.MaxDepth > 0 {
+		filters = append(filters, fmt.Sprintf("Depth: %d", sel.hierarchy.MaxDepth))
+	}
+	if sel.trees && sel.hierarchy.GroupsOnly {
+		filters = append(filters, "Without projects")
+	}
+	if sel.trees && sel.pruneEmpty {
+		filters = append(filters, "Empty groups pruned")
+	}
+	if sel.trees && sel.treeFilter != "" {
+		filters = append(filters, "Filter: "+sel.treeFilter)
+	}
+	if sel.limit > 0 {
+		filters = append(filters, fmt.Sprintf("Limit: %d", sel.limit))
+	}
+	if sel.sort != "" && (sel.sort != gitlab.SortPath || sel.reverse) {
+		order := "Sorted by " + string(sel.sort)
+		if sel.reverse {
+			order += ", reversed"
+		}
+		filters = append(filters, order)
+	}
+	return filters
+}
+
+// inventory is the set of groups and projects a selection yields.
+type inventory struct {
+	groups   []gitlab.Group   // Sorted by path, without duplicates
+	projects []gitlab.Project // Sorted by path, without duplicates
+	trees    []gitlab.Group   // Populated hierarchies, if selected
+}
+
+// fetchInventory fetches the groups and projects sel selects, reporting
+// progress on status. In hierarchy mode every group and project in the
+// populated trees is included, unless sel.matches asks for just the
+// matching ones alongside the trees.
+func fetchInventory(client *gitlab.Client, sel selection, status *termui.Status) (inventory, error) {
+	var inv inventory
+	var err error
+	if sel.trees {
+		roots, err := gitlab.Collect(client.Groups(sel.searchTerm, sel.allItems), sel.limit)
+		if err != nil {
+			return inv, fmt.Errorf("fetching groups: %w", err)
+		}
+		gitlab.SortGroups(roots, gitlab.SortPath, false)
+		if sel.matches {
+			inv.groups = append(inv.groups, roots...)
+			if !sel.groups {
+				inv.projects, err = collectProjects(client, sel)
+				if err != nil {
+					return inv, err
+				}
+			}
+		}
+		for i, root := range roots {
+			// Groups nested inside another matching group are already in its tree
+			if isWithin(root.FullPath, inv.trees) {
+				continue
+			}
+			status.SetMessage(fmt.Sprintf("[%d/%d] Populating: %s", i+1, len(roots), root.FullPath))
+			if err := client.PopulateHierarchy(&root, sel.hierarchy); err != nil {
+				return inv, fmt.Errorf("populating %s: %w", root.FullPath, err)
+			}
+			if sel.pruneEmpty || sel.treeFilter != "" {
+				if !gitlab.PruneHierarchy(&root, sel.pruneOptions()) && sel.treeFilter != "" {
+					continue
+				}
+			}
+			inv.trees = append(inv.trees, root)
+			if !sel.matches {
+				groups, projects := gitlab.Flatten(root)
+				inv.groups = append(inv.groups, groups...)
+				inv.projects = append(inv.projects, projects...)
+			}
+		}
+	} else {
+		if !sel.projects {
+			inv.groups, err = gitlab.Collect(client.Groups(sel.searchTerm, sel.allItems), sel.limit)
+			if err != nil {
+				return inv, fmt.Errorf("fetching groups: %w", err)
+			}
+		}
+		if !sel.groups {
+			inv.projects, err = collectProjects(client, sel)
+			if err != nil {
+				return inv, err
+			}
+		}
+	}
+	inv.groups = uniqueGroups(inv.groups)
+	inv.projects = uniqueProjects(inv.projects)
+	return inv, nil
+}
+
+// collectProjects fetches the projects matching sel's search.
+func collectProjects(client *gitlab.Client, sel selection) ([]gitlab.Project, error) {
+	projects := client.Projects(sel.searchTerm, sel.allItems)
+	if sel.limit > 0 {
+		// Keep the most recently active rather than the oldest projects
+		projects = client.ProjectsBy(sel.searchTerm, sel.allItems, gitlab.SortActivity, false)
+	}
+	all, err := gitlab.Collect(projects, sel.limit)
+	if err != nil {
+		return nil, fmt.Errorf("fetching projects: %w", err)
+	}
+	return all, nil
+}
+
+// uniqueGroups sorts groups by path and drops repeated IDs.
+func uniqueGroups(groups []gitlab.Group) []gitlab.Group {
+	gitlab.SortGroups(groups, gitlab.SortPath, false)
+	seen := make(map[int]bool)
+	unique := groups[:0]
+	for _, g := range groups {
+		if !seen[g.ID] {
+			seen[g.ID] = true
+			unique = append(unique, g)
+		}
+	}
+	return unique
+}
+
+// uniqueProjects sorts projects by path and drops repeated IDs.
+func uniqueProjects(projects []gitlab.Project) []gitlab.Project {
+	gitlab.SortProjects(projects, gitlab.SortPath, false)
+	seen := make(map[int]bool)
+	unique := projects[:0]
+	for _, p := range projects {
+		if !seen[p.ID] {
+			seen[p.ID] = true
+			unique = append(unique, p)
+		}
+	}
+	return unique
+}
+
+// isWithin reports whether path is one of trees' roots or lies beneath one.
+// With --depth, a nested group's own tree may go deeper than its ancestor's,
+// but each group is shown once.
+func isWithin(path string, trees []gitlab.Group) bool {
+	for _, tree := range trees {
+		if path == tree.FullPath || strings.HasPrefix(path, tree.FullPath+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -1,18 +1,22 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
-	"sort"
 	"strings"
+	"text/template"
 	"time"
 
-	"glids/internal/display"
-	"glids/internal/gitlab"
-	"golang.org/x/term" // <-- Import term
+	"github.com/bboles/glids/internal/display"
+	"github.com/bboles/glids/internal/gitlab"
+	"github.com/bboles/glids/internal/recorder"
+	"github.com/bboles/glids/internal/termui"
 )
 
 var (
@@ -27,108 +31,265 @@ var (
 	Version    = "devel"
 )
 
-// Helper function to manage status messages with progress indicator
-// Accepts a channel to pause/resume the animation
-func showStatus(message string, pauseControl <-chan bool) func() {
-	stderrFd := int(os.Stderr.Fd())
-	isTerminal := term.IsTerminal(stderrFd)
-
-	// If stderr is not a terminal, just print the message once and do nothing else.
-	if !isTerminal {
-		fmt.Fprintln(os.Stderr, message+"...") // Indicate work is starting
-		return func() {}                       // Return a no-op closer
-	}
-
-	// --- Terminal-specific logic ---
-	progressChars := []string{"|", "/", "-", "\\"}
-	progressIndex := 0
-	ticker := time.NewTicker(100 * time.Millisecond)
-	done := make(chan bool)
-	paused := false
-
-	// Function to clear the status line using ANSI escape code
-	clearLine := func() {
-		fmt.Fprint(os.Stderr, "\r\x1b[K") // Carriage return, clear line to end
-	}
-
-	// Start goroutine to animate progress
-	go func() {
-		// Ensure line is cleared when goroutine exits (e.g., on success/completion)
-		// We clear *before* printing the final state or letting the main flow continue.
-		defer clearLine()
-
-		for {
-			select {
-			case <-ticker.C:
-				if !paused {
-					clearLine() // Clear previous status
-					// Print status with animation character
-					fmt.Fprintf(os.Stderr, "\r%s %s", message, progressChars[progressIndex%len(progressChars)])
-					progressIndex++
-				}
-			case p, ok := <-pauseControl: // Listen for pause signals
-				if !ok { // Channel closed, treat as done
-					return
-				}
-				paused = p
-				clearLine() // Clear the line when pausing or resuming
-				if !paused {
-					// Optional: Immediately print status without animation char when resuming
-					fmt.Fprintf(os.Stderr, "\r%s  ", message) // Two spaces to overwrite animation char
-				}
-			case <-done:
-				ticker.Stop() // Stop the ticker explicitly
-				return        // Exit goroutine
-			}
-		}
-	}()
+// subcommands maps the first argument to a command with its own flags.
+// Anything else is handled by the default listing command.
+var subcommands = map[string]func(args []string){
+	"report": runReport,
+	"gen":    runGen,
+	"serve":  runServe,
+}
 
-	// Return the function to stop the animation
-	return func() {
-		select {
-		case <-done: // Already closed
+func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			run(os.Args[2:])
 			return
-		default:
-			close(done) // Signal the goroutine to stop
-			// Give the goroutine a moment to finish and clear the line via its defer
-			// This prevents subsequent output potentially overwriting the status line
-			// before the goroutine's defer clearLine executes.
-			// Adjust timing if needed, or use a sync mechanism if more robustness is required.
-			time.Sleep(50 * time.Millisecond)
 		}
 	}
-}
 
-func main() {
 	// --- Configuration and Setup ---
 	searchTerm := flag.String("search", "", "Search term to filter projects or groups")
 	allItems := flag.Bool("all", false, "List all projects/groups regardless of activity date")
 	showGroups := flag.Bool("groups", false, "Show groups only (default is to show both)")
 	showHierarchy := flag.Bool("hierarchy", false, "Show groups, subgroups, and projects in hierarchical format")
 	showProjects := flag.Bool("projects", false, "Show projects only (default is to show both)")
-	hostFlag := flag.String("host", "", "GitLab server host (e.g., gitlab.example.com). Overrides GITLAB_HOST env var.")
-	debug := flag.Bool("debug", false, "Enable debug logging")
-	noHttps := flag.Bool("nohttps", false, "Turn off SSL/TLS")
+	depth := flag.Int("depth", 0, "With --hierarchy, stop after this many levels below each matching group (0 for no limit)")
+	groupsOnly := flag.Bool("groups-only", false, "With --hierarchy, show subgroups but no projects")
+	pruneEmpty := flag.Bool("prune-empty", false, "With --hierarchy, drop groups with no projects beneath them")
+	treeFilter := flag.String("filter", "", "With --hierarchy, keep only projects whose name contains this (subgroups with --groups-only), plus their ancestors")
+	showStats := flag.Bool("stats", false, "With --hierarchy, annotate each group with project/subgroup counts and activity, and summarise each root")
+	limit := flag.Int("limit", 0, "Stop after this many matching groups/projects (0 for no limit)")
+	output := flag.String("output", "text", "Output format: text, json, jsonl, csv, markdown, yaml, env, terraform, or with --hierarchy also dot or mermaid")
+	envPrefix := flag.String("env-prefix", display.DefaultEnvPrefix, "With --output env, prefix for variable names")
+	links := flag.Bool("links", false, "With --output markdown, add links to each group's and project's GitLab page")
+	outputPath := flag.String("output-file", "", "Write results to this file, replacing it only once they are complete")
+	force := flag.Bool("force", false, "With --output-file, overwrite an existing file")
+	metadata := flag.Bool("metadata", false, `With --output json or yaml, record the host, filters, time and glids version: JSON becomes {"metadata": ..., "items": [...]}, YAML gets comment lines`)
+	colorMode := flag.String("color", "auto", "Colour text output: auto (when stdout is a terminal and NO_COLOR is unset), always or never")
+	ascii := flag.Bool("ascii", false, "Draw trees with ASCII characters only")
+	sortFlag := flag.String("sort", "", "Sort by path, name, id, activity (newest first), created (newest first) or size (largest first) (default path, and name within --hierarchy trees)")
+	reverse := flag.Bool("reverse", false, "Reverse the sort order")
+	stream := flag.Bool("stream", false, "Print each result as soon as its page is received, in GitLab's order (see --sort)")
+	var interactive bool
+	flag.BoolVar(&interactive, "i", false, "Pick a group or project interactively and print its ID")
+	flag.BoolVar(&interactive, "interactive", false, "Same as -i")
+	ancestors := flag.String("ancestors", "", "Show the groups above this project or group path (or ID), from the top-level group down")
+	siblings := flag.Bool("siblings", false, "With --ancestors, also show the other subgroups and projects at each level")
+	browse := flag.Bool("browse", false, "Browse the hierarchy of matching groups interactively, fetching each group only when expanded")
+	templateFlag := flag.String("template", "", "With -i, print this Go template (fields .Kind .ID .Path .Name) instead of the ID")
+	conn := addConnFlags(flag.CommandLine, 0)
 	version := flag.Bool("version", false, "Show version")
 	flag.Parse()
 
 	if *version {
 		fmt.Printf("%s %s (%s) %s\n", executableName, Version, CommitSHA[:7], CommitDate)
-		os.Exit(0)
+		exit(0)
+	}
+
+	format, err := display.ParseFormat(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		exit(2)
+	}
+	if format.TreeOnly() && !*showHierarchy {
+		fmt.Fprintf(os.Stderr, "Error: --output %s is only supported with --hierarchy\n", format)
+		exit(2)
+	}
+	if format == display.FormatEnv {
+		if err := display.ValidateEnvPrefix(*envPrefix); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			exit(2)
+		}
+	}
+	if *metadata && format != display.FormatJSON && format != display.FormatYAML {
+		fmt.Fprintln(os.Stderr, "Error: --metadata is only supported with --output json or yaml")
+		exit(2)
+	}
+	if *showStats && format != display.FormatText {
+		fmt.Fprintln(os.Stderr, "Error: --stats is only supported with --output text")
+		exit(2)
 	}
 
+	// Lists default to path order; trees keep name order unless --sort is given
+	sortKey, treeSort := gitlab.SortPath, gitlab.SortKey("")
+	if *sortFlag != "" {
+		key, err := gitlab.ParseSortKey(*sortFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			exit(2)
+		}
+		sortKey, treeSort = key, key
+	}
+	if *ancestors == "" && !interactive && !*browse {
+		sorted := isFlagSet("sort") || *reverse
+		if err := checkSort(sortKey, sorted && *stream, *showHierarchy, *showGroups, *showProjects); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			exit(2)
+		}
+	}
+
+	var pickTemplate *template.Template
+	if *templateFlag != "" {
+		pickTemplate, err = template.New("pick").Parse(*templateFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: invalid --template:", err)
+			exit(2)
+		}
+	}
+
+	// Fail before fetching anything if the output file can't be written
+	out := io.Writer(os.Stdout)
+	var file *outputFile
+	if *outputPath != "" {
+		file, err = createOutputFile(*outputPath, *force)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			exit(1)
+		}
+		out = file
+	}
+	color, err := useColor(*colorMode, out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		exit(2)
+	}
+
+	client, status := conn.setup()
+
+	// Get positional arguments as search term if provided
+	if flag.NArg() > 0 {
+		*searchTerm = flag.Arg(0)
+		debugLogger.Printf("Using positional argument for search term: %s", *searchTerm)
+	}
+
+	// --- Execution Logic ---
+	// Determine the initial status message based on the mode
+	statusMessage := "Fetching data..."
+	if *ancestors != "" {
+		statusMessage = "Resolving ancestors..."
+	} else if interactive {
+		statusMessage = "Fetching groups and projects to pick from..."
+	} else if *browse {
+		statusMessage = "Fetching groups to browse..."
+	} else if *showHierarchy {
+		statusMessage = "Fetching initial groups for hierarchy..."
+	} else if *showGroups {
+		statusMessage = "Fetching groups..."
+	} else if *showProjects {
+		statusMessage = "Fetching projects..."
+	} else {
+		statusMessage = "Fetching groups and projects..."
+	}
+
+	// Start status only if not in debug mode; it prints a plain line when
+	// stderr is not a terminal. If debug is enabled, the status is skipped entirely.
+	if !isDebug {
+		status.Start(statusMessage)
+	}
+
+	opts := runOptions{
+		searchTerm: *searchTerm,
+		allItems:   *allItems,
+		limit:      *limit,
+		format:     format,
+		stream:     *stream,
+		hierarchy: gitlab.HierarchyOptions{
+			AllItems:   *allItems,
+			MaxDepth:   *depth,
+			GroupsOnly: *groupsOnly,
+			Sort:       treeSort,
+			Reverse:    *reverse,
+		},
+		pruneEmpty: *pruneEmpty,
+		treeFilter: *treeFilter,
+		stats:      *showStats,
+		sort:       sortKey,
+		reverse:    *reverse,
+		encoding:   []display.EncoderOption{display.WithLinks(*links), display.WithEnvPrefix(*envPrefix)},
+		out:        out,
+		outputPath: *outputPath,
+		printing:   []display.PrintOption{display.WithColor(color), display.WithASCII(*ascii), display.WithHighlight(*searchTerm)},
+	}
+	opts.encoding = append(opts.encoding, display.WithTextOptions(opts.printing...))
+	if *metadata {
+		host := client.BaseURL()
+		if u, err := url.Parse(host); err == nil {
+			host = u.Host
+		}
+		sel := selection{runOptions: opts, groups: *showGroups, projects: *showProjects, trees: *showHierarchy}
+		opts.encoding = append(opts.encoding, display.WithMetadata(&display.Metadata{
+			Host:        host,
+			Filters:     sel.describe(),
+			GeneratedAt: time.Now(),
+			Version:     Version,
+		}))
+	}
+
+	// Select mode and run
+	if *ancestors != "" {
+		runAncestorsMode(client, *ancestors, *siblings, opts, status)
+	} else if interactive {
+		runInteractiveMode(client, opts, status, pickTemplate)
+	} else if *browse {
+		runBrowseMode(client, opts, status)
+	} else if *showHierarchy {
+		runHierarchyMode(client, opts, status)
+	} else if *showGroups {
+		runGroupsMode(client, opts, status)
+	} else if *showProjects {
+		runProjectsMode(client, opts, status)
+	} else {
+		runBothMode(client, opts, status)
+	}
+
+	// clearStatus() // This is now handled by the defer in each run*Mode function
+
+	if file != nil {
+		if err := file.commit(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing output file:", err)
+			exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", *outputPath)
+	}
+}
+
+// connFlags holds the connection and logging flags shared by every command.
+type connFlags struct {
+	host    *string
+	debug   *bool
+	noHTTPS *bool
+	record  *string
+	replay  *string
+	retries *int
+}
+
+// addConnFlags registers the connection and logging flags on fs. retries is
+// the default for --retries: one-off commands fail fast, while long-running
+// ones ride out brief outages.
+func addConnFlags(fs *flag.FlagSet, retries int) connFlags {
+	return connFlags{
+		host:    fs.String("host", "", "GitLab server host (e.g., gitlab.example.com). Overrides GITLAB_HOST env var."),
+		debug:   fs.Bool("debug", false, "Enable debug logging"),
+		noHTTPS: fs.Bool("nohttps", false, "Turn off SSL/TLS"),
+		record:  fs.String("record", "", "Save every GitLab API request and response in this directory (tokens are scrubbed)"),
+		replay:  fs.String("replay", "", "Answer GitLab API requests from a directory written by --record, without network access"),
+		retries: fs.Int("retries", retries, "Retry GitLab API requests this many times after a network error, 5xx answer or rate limit"),
+	}
+}
+
+// setup enables debug logging if requested and creates the GitLab client,
+// together with the status display subscribed to its events. Extra options
+// are applied after the defaults. It exits if the token or host is missing.
+func (cf connFlags) setup(opts ...gitlab.Option) (*gitlab.Client, *termui.Status) {
 	// Setup debug logging
-	isDebug = *debug
+	isDebug = *cf.debug
 	logOutput := io.Discard // Default to discard
 	if isDebug {
 		logOutput = os.Stderr // Use Stderr for debug logs
 		// No need to create the logger yet, we might need terminal info first
 	}
 
-	// Determine if Stderr is a terminal *before* potentially overwriting debugLogger
-	isStderrTerminal := term.IsTerminal(int(os.Stderr.Fd()))
-
-	// Initialize debugLogger *after* checking terminal status
+	// Initialize debugLogger
 	if isDebug {
 		prefix := "[DEBUG] "
 		// Add extra newline if stderr is a terminal to avoid clashing with status line
@@ -143,14 +304,8 @@ func main() {
 		debugLogger = log.New(io.Discard, "", 0)
 	}
 
-	// Get positional arguments as search term if provided
-	if flag.NArg() > 0 {
-		*searchTerm = flag.Arg(0)
-		debugLogger.Printf("Using positional argument for search term: %s", *searchTerm)
-	}
-
 	// Determine GitLab host: prioritize flag, then env var
-	gitlabHost := *hostFlag
+	gitlabHost := *cf.host
 	if gitlabHost == "" {
 		debugLogger.Println("Host flag not provided, checking GITLAB_HOST environment variable.")
 		gitlabHost = os.Getenv("GITLAB_HOST")
@@ -163,12 +318,43 @@ func main() {
 
 	// Get token and validate host
 	gitlabToken := os.Getenv("GITLAB_TOKEN")
+	var transport http.RoundTripper
+	switch {
+	case *cf.record != "" && *cf.replay != "":
+		fmt.Fprintln(os.Stderr, "Error: --record and --replay cannot be used together")
+		exit(2)
+	case *cf.record != "":
+		rec, err := recorder.NewRecorder(*cf.record)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			exit(1)
+		}
+		transport = rec
+	case *cf.replay != "":
+		rep, err := recorder.NewReplayer(*cf.replay)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			exit(1)
+		}
+		transport = rep
+		// Replayed responses don't depend on the host or token
+		if gitlabHost == "" {
+			gitlabHost = "replay.invalid"
+		}
+		if gitlabToken == "" {
+			gitlabToken = "replay"
+		}
+	}
+	if *cf.retries < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --retries must not be negative")
+		exit(2)
+	}
 	if gitlabToken == "" || gitlabHost == "" {
 		fmt.Fprintln(os.Stderr, "Error: GITLAB_TOKEN environment variable must be set, and GitLab host must be provided via --host flag or GITLAB_HOST environment variable.")
-		os.Exit(1)
+		exit(1)
 	}
 
-	disableHttps = *noHttps
+	disableHttps = *cf.noHTTPS
 	var baseURL string
 	// Construct base URL assuming
 	if disableHttps || os.Getenv("GLIDS_NOHTTPS") == "true" {
@@ -177,188 +363,206 @@ func main() {
 		baseURL = "https://" + gitlabHost
 	}
 
-	// --- Create Pause Channel ---
-	// Use a buffered channel to prevent potential blocking if the signal is sent
-	// before the receiver in showStatus is ready, although unlikely with current setup.
-	pauseCh := make(chan bool, 1)
-
-	// Create GitLab client, passing the pause channel
-	client := gitlab.NewClient(baseURL, gitlabToken, debugLogger, pauseCh)
+	// --- Status Display ---
+	// The status display subscribes to client events, so it can step aside
+	// while the client asks for confirmation.
+	status := termui.NewStatus(os.Stderr)
+
+	// Create GitLab client, wiring in the terminal prompt and status display
+	defaults := []gitlab.Option{
+		gitlab.WithLogger(debugLogger),
+		gitlab.WithConfirmFunc(termui.Confirm),
+		gitlab.WithObserver(status),
+		gitlab.WithRetries(*cf.retries),
+	}
+	if transport != nil {
+		defaults = append(defaults, gitlab.WithHTTPClient(&http.Client{Transport: transport}))
+	}
+	client := gitlab.NewClient(baseURL, gitlabToken, append(defaults, opts...)...)
 
-	var clearStatus func() = func() {} // No-op clear function initially
+	return client, status
+}
 
-	// --- Execution Logic ---
-	// Determine the initial status message based on the mode
-	statusMessage := "Fetching data..."
-	if *showHierarchy {
-		statusMessage = "Fetching initial groups for hierarchy..."
-	} else if *showGroups {
-		statusMessage = "Fetching groups..."
-	} else if *showProjects {
-		statusMessage = "Fetching projects..."
-	} else {
-		statusMessage = "Fetching groups and projects..."
-	}
+// runOptions holds the flags shared by every mode.
+type runOptions struct {
+	searchTerm string
+	allItems   bool
+	limit      int // Stop after this many matches per listing; 0 for no limit
+	format     display.Format
+	stream     bool // Print results as they arrive instead of sorted at the end
+	hierarchy  gitlab.HierarchyOptions
+	pruneEmpty bool   // Drop subgroups with nothing beneath them
+	treeFilter string // Keep only branches with matching project (or, with GroupsOnly, subgroup) names
+	stats      bool   // Annotate hierarchy output with gitlab.GroupStats
+	sort       gitlab.SortKey
+	reverse    bool // Reverse the sort order
+	encoding   []display.EncoderOption
+	out        io.Writer             // Where results go: stdout or the --output-file
+	outputPath string                // The --output-file, if any
+	printing   []display.PrintOption // Colour, ASCII trees and highlighting for text output
+}
 
-	// Start status only if not in debug mode AND stderr is a terminal
-	if !isDebug && isStderrTerminal {
-		clearStatus = showStatus(statusMessage, pauseCh)
-	} else if !isDebug {
-		// If not debug and not a terminal, print the initial message simply
-		fmt.Fprintln(os.Stderr, statusMessage+"...")
+// pruneOptions converts the hierarchy flags to gitlab.PruneOptions.
+func (o runOptions) pruneOptions() gitlab.PruneOptions {
+	prune := gitlab.PruneOptions{PruneEmpty: o.pruneEmpty}
+	if o.treeFilter == "" {
+		return prune
 	}
-	// If debug is enabled, status indicator is skipped entirely.
-
-	// Select mode and run
-	if *showHierarchy {
-		runHierarchyMode(client, *searchTerm, *allItems, clearStatus, pauseCh) // Pass pauseCh for potential restarts
-	} else if *showGroups {
-		runGroupsMode(client, *searchTerm, *allItems, clearStatus)
-	} else if *showProjects {
-		runProjectsMode(client, *searchTerm, *allItems, clearStatus)
+	lowerFilter := strings.ToLower(o.treeFilter)
+	if o.hierarchy.GroupsOnly {
+		prune.KeepGroup = func(g gitlab.Group) bool {
+			return strings.Contains(strings.ToLower(g.Name), lowerFilter)
+		}
 	} else {
-		runBothMode(client, *searchTerm, *allItems, clearStatus)
+		prune.KeepProject = func(p gitlab.Project) bool {
+			return strings.Contains(strings.ToLower(p.Name), lowerFilter)
+		}
 	}
-
-	// clearStatus() // This is now handled by the defer in each run*Mode function
+	return prune
 }
 
-// Pass pauseCh to runHierarchyMode in case we want to restart status during population
-func runHierarchyMode(client *gitlab.Client, searchTerm string, allItems bool, clearStatus func(), pauseCh chan bool) {
-	defer clearStatus() // Stops the initial status animation when the function exits
+// runHierarchyMode fetches matching groups and prints each one's populated tree.
+// The status display is reused to report population progress.
+func runHierarchyMode(client *gitlab.Client, opts runOptions, status *termui.Status) {
+	defer status.Stop() // Stops the status animation when the function exits
 
-	debugLogger.Printf("Running in hierarchy mode, search term: '%s'", searchTerm)
+	debugLogger.Printf("Running in hierarchy mode, search term: '%s'", opts.searchTerm)
 
 	// Fetch initial matching groups (roots of the trees)
 	// The confirmation logic (including pausing) is now inside GetGroups
-	matchingGroups, err := client.GetGroups(searchTerm, allItems)
+	matchingGroups, err := gitlab.Collect(listGroups(client, opts), opts.limit)
 	if err != nil {
-		// clearStatus() is handled by defer
+		status.Stop()
 		// Check if error is cancellation
-		if err.Error() == "operation cancelled by user" {
-			fmt.Println("\nOperation cancelled.") // Give user feedback
-			os.Exit(0)                            // Exit cleanly after cancellation
+		if errors.Is(err, gitlab.ErrCancelled) {
+			fmt.Fprintln(os.Stderr, "\nOperation cancelled.") // Give user feedback
+			exit(0)                                           // Exit cleanly after cancellation
 		}
 		// Print other errors on a new line
 		fmt.Fprintf(os.Stderr, "\nError getting initial groups: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
 
-	clearStatus()
-
 	debugLogger.Printf("Found %d initial matching groups", len(matchingGroups))
 
-	// Defer handles clearing the status line now.
-
 	if len(matchingGroups) == 0 {
-		fmt.Println("\nNo groups found matching search term:", searchTerm)
+		status.Stop()
+		notice(opts, "\nNo groups found matching search term:", opts.searchTerm)
 		return // Exit gracefully
 	}
 
-	// Sort the initial matching groups by path for consistent output order
-	sort.Slice(matchingGroups, func(i, j int) bool {
-		return strings.ToLower(matchingGroups[i].FullPath) < strings.ToLower(matchingGroups[j].FullPath)
-	})
+	// Populate the trees in the final order, so --stream prints them in it,
+	// unless the order needs the populated trees; then it's applied afterwards
+	if opts.sort.NeedsPopulation() {
+		gitlab.SortGroups(matchingGroups, gitlab.SortPath, false)
+	} else {
+		gitlab.SortGroups(matchingGroups, opts.sort, opts.reverse)
+	}
 
-	fmt.Println("Populating hierarchy for found groups...") // Indicate next step
+	status.Stop()
+	notice(opts, "Populating hierarchy for found groups...") // Indicate next step
 
-	clearStatus()
+	// In stream mode each tree is printed as soon as it is populated
+	enc := newStdoutEncoder(opts)
+	printTree := func(group gitlab.Group) {
+		if opts.stats {
+			encodeOrExit(display.FprintHierarchy(opts.out, group, append(opts.printing, display.WithStats(true))...))
+			return
+		}
+		encodeOrExit(enc.Tree(group))
+	}
 
 	// --- Populate Hierarchy ---
 	populatedGroups := make([]gitlab.Group, 0, len(matchingGroups))
 	populationCancelled := false
-	stderrFd := int(os.Stderr.Fd())
-	isTerminal := term.IsTerminal(stderrFd)
-	terminalWidth := 80 // Default width if not a terminal or size check fails
-	if isTerminal {
-		width, _, err := term.GetSize(stderrFd)
-		if err == nil {
-			terminalWidth = width
-		} else {
-			debugLogger.Printf("Warning: Could not get terminal size: %v", err)
-		}
-	}
 
 	// --- Population Loop ---
+	// The status keeps running across all roots so its progress counts
+	// aggregate every subgroup listing.
+	if !isDebug {
+		status.Start("Populating hierarchy")
+	}
 	for i, group := range matchingGroups {
-		// Print status update for the current group BEFORE processing
-		statusLine := fmt.Sprintf("[%d/%d] Populating: %s...", i+1, len(matchingGroups), group.FullPath)
-		if isTerminal {
-			// Truncate status line if it's too long for the terminal width
-			maxLen := terminalWidth - 1 // Leave room for cursor potentially
-			if len(statusLine) > maxLen {
-				// Truncate with ellipsis, ensuring space for "..."
-				if maxLen > 3 {
-					statusLine = statusLine[:maxLen-3] + "..."
-				} else { // Very narrow terminal
-					statusLine = statusLine[:maxLen]
-				}
-			}
-			// Use ANSI clear code \r\x1b[K (carriage return, clear line)
-			fmt.Fprintf(os.Stderr, "\r\x1b[K%s", statusLine)
-		} else {
-			// Non-terminal: just print the status line on its own line
-			fmt.Fprintln(os.Stderr, statusLine)
-		}
+		// Update the status line for the current group BEFORE processing
+		status.SetMessage(fmt.Sprintf("[%d/%d] Populating: %s", i+1, len(matchingGroups), group.FullPath))
 
 		rootGroup := group // Make a copy
-		err := client.PopulateGroupHierarchy(&rootGroup, allItems)
-
-		// Clear the status line *before* printing errors/warnings/cancellation or moving to the next item
-		if isTerminal {
-			fmt.Fprint(os.Stderr, "\r\x1b[K")
-		}
+		err := client.PopulateHierarchy(&rootGroup, opts.hierarchy)
 
 		if err != nil {
-			// Error handling remains the same, but the status line is already cleared
-			if err.Error() == "operation cancelled by user" {
-				fmt.Println("\nOperation cancelled during hierarchy population.")
+			if errors.Is(err, gitlab.ErrCancelled) {
+				status.Stop()
+				fmt.Fprintln(os.Stderr, "\nOperation cancelled during hierarchy population.")
 				populationCancelled = true
 				break // Exit the loop
 			}
-			// Print warning on a new line (status line is clear)
-			fmt.Fprintf(os.Stderr, "\nWarning: Failed to fully populate group %s (ID: %d): %v\n", rootGroup.FullPath, rootGroup.ID, err)
+			// Print warning above the status line
+			status.Println(fmt.Sprintf("Warning: Failed to fully populate group %s (ID: %d): %v", rootGroup.FullPath, rootGroup.ID, err))
 			// Continue processing other groups
 		}
+		// Drop filtered-out branches; roots with no matches at all are skipped
+		if opts.pruneEmpty || opts.treeFilter != "" {
+			if !gitlab.PruneHierarchy(&rootGroup, opts.pruneOptions()) && opts.treeFilter != "" {
+				debugLogger.Printf("No matches for filter '%s' under group %s", opts.treeFilter, rootGroup.FullPath)
+				continue
+			}
+		}
+
 		// Add fully or partially populated groups (unless cancelled)
 		populatedGroups = append(populatedGroups, rootGroup)
+		if opts.stream {
+			status.Above(func() { printTree(rootGroup) })
+		}
 	}
+	status.Stop()
 	// --- End Population Loop ---
 
-	// Final clear of the status line (might be redundant if loop finished cleanly, but safe)
-	if isTerminal {
-		fmt.Fprint(os.Stderr, "\r\x1b[K")
-	}
-
 	// --- Print Results ---
 	if len(populatedGroups) > 0 {
-		for _, group := range populatedGroups {
-			display.PrintHierarchy(group)
+		if !opts.stream {
+			gitlab.SortGroups(populatedGroups, opts.sort, opts.reverse)
+			for _, group := range populatedGroups {
+				printTree(group)
+			}
 		}
 	} else if !populationCancelled { // Only print "no groups" if not cancelled
 		// Ensure this message starts on a new line
-		fmt.Println("\nNo groups found or populated.")
+		notice(opts, "\nNo groups found or populated.")
 	}
+	encodeOrExit(enc.Close())
 
 	// If cancelled during population, exit cleanly now
 	if populationCancelled {
-		os.Exit(0)
+		exit(0)
 	}
 }
 
-func runGroupsMode(client *gitlab.Client, searchTerm string, allItems bool, clearStatus func()) {
+func runGroupsMode(client *gitlab.Client, opts runOptions, status *termui.Status) {
+	clearStatus := status.Stop
 	defer clearStatus() // Stops status animation on exit
 
-	debugLogger.Printf("Running in groups mode, search term: '%s'", searchTerm)
-	groups, err := client.GetGroups(searchTerm, allItems)
+	debugLogger.Printf("Running in groups mode, search term: '%s'", opts.searchTerm)
+	if opts.stream {
+		enc := newStdoutEncoder(opts)
+		n, err := streamInto(listGroups(client, opts), opts.limit, status, enc.Group)
+		clearStatus()
+		exitOnFetchError(err, "groups")
+		encodeOrExit(enc.Close())
+		if n == 0 {
+			notice(opts, "\nNo groups found matching search term:", opts.searchTerm)
+		}
+		return
+	}
+
+	groups, err := gitlab.Collect(listGroups(client, opts), opts.limit)
 	if err != nil {
 		// clearStatus() handled by defer
-		if err.Error() == "operation cancelled by user" {
-			fmt.Println("\nOperation cancelled.")
-			os.Exit(0)
+		if errors.Is(err, gitlab.ErrCancelled) {
+			fmt.Fprintln(os.Stderr, "\nOperation cancelled.")
+			exit(0)
 		}
 		fmt.Fprintf(os.Stderr, "\nError getting groups: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	clearStatus()
@@ -368,30 +572,44 @@ func runGroupsMode(client *gitlab.Client, searchTerm string, allItems bool, clea
 	// Defer handles clearing the status line now.
 
 	if len(groups) == 0 {
-		fmt.Println("\nNo groups found matching search term:", searchTerm)
+		notice(opts, "\nNo groups found matching search term:", opts.searchTerm)
 		return
 	}
 
-	// Sort groups by path name before display
-	sort.Slice(groups, func(i, j int) bool {
-		return strings.ToLower(groups[i].FullPath) < strings.ToLower(groups[j].FullPath)
-	})
+	gitlab.SortGroups(groups, opts.sort, opts.reverse)
 
-	display.PrintGroupList(groups, 0) // Pass 0 for nameWidth, tabwriter auto-sizes
+	if opts.format != display.FormatText {
+		encodeLists(opts, groups, nil)
+		return
+	}
+	encodeOrExit(display.FprintGroupList(opts.out, groups, opts.printing...)) // The path column is sized to fit
 }
 
-func runProjectsMode(client *gitlab.Client, searchTerm string, allItems bool, clearStatus func()) {
+func runProjectsMode(client *gitlab.Client, opts runOptions, status *termui.Status) {
+	clearStatus := status.Stop
 	defer clearStatus() // Stops status animation on exit
 
-	debugLogger.Printf("Running in projects mode, search term: '%s'", searchTerm)
-	projects, err := client.GetProjects(searchTerm, allItems)
+	debugLogger.Printf("Running in projects mode, search term: '%s'", opts.searchTerm)
+	if opts.stream {
+		enc := newStdoutEncoder(opts)
+		n, err := streamInto(listProjects(client, opts), opts.limit, status, enc.Project)
+		clearStatus()
+		exitOnFetchError(err, "projects")
+		encodeOrExit(enc.Close())
+		if n == 0 {
+			notice(opts, "\nNo projects found matching search term:", opts.searchTerm)
+		}
+		return
+	}
+
+	projects, err := gitlab.Collect(listProjects(client, opts), opts.limit)
 	if err != nil {
-		if err.Error() == "operation cancelled by user" {
-			fmt.Println("\nOperation cancelled.")
-			os.Exit(0)
+		if errors.Is(err, gitlab.ErrCancelled) {
+			fmt.Fprintln(os.Stderr, "\nOperation cancelled.")
+			exit(0)
 		}
 		fmt.Fprintf(os.Stderr, "\nError getting projects: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	clearStatus()
@@ -399,46 +617,65 @@ func runProjectsMode(client *gitlab.Client, searchTerm string, allItems bool, cl
 	debugLogger.Printf("Found %d projects", len(projects))
 
 	if len(projects) == 0 {
-		fmt.Println("\nNo projects found matching search term:", searchTerm)
+		notice(opts, "\nNo projects found matching search term:", opts.searchTerm)
 		return
 	}
 
-	// Sort projects by path name before display
-	sort.Slice(projects, func(i, j int) bool {
-		return strings.ToLower(projects[i].PathWithNamespace) < strings.ToLower(projects[j].PathWithNamespace)
-	})
+	gitlab.SortProjects(projects, opts.sort, opts.reverse)
 
-	display.PrintProjectList(projects, 0) // Pass 0 for nameWidth, tabwriter auto-sizes
+	if opts.format != display.FormatText {
+		encodeLists(opts, nil, projects)
+		return
+	}
+	encodeOrExit(display.FprintProjectList(opts.out, projects, opts.printing...)) // The path column is sized to fit
 }
 
-func runBothMode(client *gitlab.Client, searchTerm string, allItems bool, clearStatus func()) {
+func runBothMode(client *gitlab.Client, opts runOptions, status *termui.Status) {
+	clearStatus := status.Stop
 	defer clearStatus() // Stops status animation on exit
 
-	debugLogger.Printf("Running in both mode, search term: '%s'", searchTerm)
+	debugLogger.Printf("Running in both mode, search term: '%s'", opts.searchTerm)
+	if opts.stream {
+		// Groups first, then projects, each printed as soon as it arrives
+		enc := newStdoutEncoder(opts)
+		nGroups, err := streamInto(listGroups(client, opts), opts.limit, status, enc.Group)
+		if err != nil {
+			clearStatus()
+			exitOnFetchError(err, "groups")
+		}
+		nProjects, err := streamInto(listProjects(client, opts), opts.limit, status, enc.Project)
+		clearStatus()
+		exitOnFetchError(err, "projects")
+		encodeOrExit(enc.Close())
+		if nGroups == 0 && nProjects == 0 {
+			notice(opts, "\nNo groups or projects found matching search term:", opts.searchTerm)
+		}
+		return
+	}
 
 	// Fetch Groups
 	debugLogger.Println("Fetching groups for both mode...")
-	groups, err := client.GetGroups(searchTerm, allItems)
+	groups, err := gitlab.Collect(listGroups(client, opts), opts.limit)
 	if err != nil {
-		if err.Error() == "operation cancelled by user" {
-			fmt.Println("\nOperation cancelled while fetching groups.")
-			os.Exit(0)
+		if errors.Is(err, gitlab.ErrCancelled) {
+			fmt.Fprintln(os.Stderr, "\nOperation cancelled while fetching groups.")
+			exit(0)
 		}
 		fmt.Fprintf(os.Stderr, "\nError getting groups: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
 	debugLogger.Printf("Found %d groups", len(groups))
 
 	// Fetch Projects
 	debugLogger.Println("Fetching projects for both mode...")
-	projects, err := client.GetProjects(searchTerm, allItems)
+	projects, err := gitlab.Collect(listProjects(client, opts), opts.limit)
 	if err != nil {
-		if err.Error() == "operation cancelled by user" {
-			fmt.Println("\nOperation cancelled while fetching projects.")
-			os.Exit(0)
+		if errors.Is(err, gitlab.ErrCancelled) {
+			fmt.Fprintln(os.Stderr, "\nOperation cancelled while fetching projects.")
+			exit(0)
 		}
 		fmt.Fprintf(os.Stderr, "\nError getting projects: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
 	debugLogger.Printf("Found %d projects", len(projects))
 
@@ -446,59 +683,24 @@ func runBothMode(client *gitlab.Client, searchTerm string, allItems bool, clearS
 	clearStatus()
 
 	if len(groups) == 0 && len(projects) == 0 {
-		fmt.Println("\nNo groups or projects found matching search term:", searchTerm)
+		notice(opts, "\nNo groups or projects found matching search term:", opts.searchTerm)
 		return
 	}
 
-	maxNameDisplayLength := 0
-	var padWhichResource string
-	for _, g := range groups {
-		length := len(g.FullPath) + 1 // +1 for colon
-		if length > maxNameDisplayLength {
-			maxNameDisplayLength = length
-			padWhichResource = "groups"
-		}
-	}
-	for _, p := range projects {
-		length := len(p.PathWithNamespace) + 1 // +1 for colon
-		if length > maxNameDisplayLength {
-			maxNameDisplayLength = length
-			padWhichResource = "projects"
-		}
-	}
-
-	debugLogger.Printf("maxNameDisplayLength set to: %d", maxNameDisplayLength)
-	debugLogger.Printf("padWhichResource set to: %s", padWhichResource)
+	gitlab.SortGroups(groups, opts.sort, opts.reverse)
+	gitlab.SortProjects(projects, opts.sort, opts.reverse)
 
-	if len(groups) > 0 {
-		fmt.Println("\nGroups:")
-		sort.Slice(groups, func(i, j int) bool {
-			return strings.ToLower(groups[i].FullPath) < strings.ToLower(groups[j].FullPath)
-		})
-
-		if padWhichResource != "groups" {
-			display.PrintGroupList(groups, maxNameDisplayLength+2)
-		} else {
-			display.PrintGroupList(groups, maxNameDisplayLength)
-		}
-
-	} else {
-		fmt.Println("\nNo groups found matching search term:", searchTerm)
+	if opts.format != display.FormatText {
+		encodeLists(opts, groups, projects)
+		return
 	}
 
-	if len(projects) > 0 {
-		fmt.Println("\nProjects:")
-		sort.Slice(projects, func(i, j int) bool {
-			return strings.ToLower(projects[i].PathWithNamespace) < strings.ToLower(projects[j].PathWithNamespace)
-		})
-
-		if padWhichResource != "projects" {
-			display.PrintProjectList(projects, maxNameDisplayLength+2)
-		} else {
-			display.PrintProjectList(projects, maxNameDisplayLength)
-		}
-
-	} else {
-		fmt.Println("\nNo projects found matching search term:", searchTerm)
+	// Notices for an empty list go where its heading would have been
+	if len(groups) == 0 {
+		notice(opts, "\nNo groups found matching search term:", opts.searchTerm)
+	}
+	encodeOrExit(display.FprintLists(opts.out, groups, projects, opts.printing...))
+	if len(projects) == 0 {
+		notice(opts, "\nNo projects found matching search term:", opts.searchTerm)
 	}
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+var (
+	exitMu    sync.Mutex
+	exitHooks []func()
+)
+
+// atExit registers fn to run when the program ends through exit.
+func atExit(fn func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitHooks = append(exitHooks, fn)
+}
+
+// exit runs the hooks registered with atExit, such as removing an
+// unfinished --output-file, and exits with code. The listing command uses
+// it instead of os.Exit wherever an output file may be open.
+func exit(code int) {
+	exitMu.Lock()
+	hooks := exitHooks
+	exitHooks = nil
+	exitMu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+	os.Exit(code)
+}
+
+// outputFile is written under a temporary name in the same directory as
+// its path and renamed into place by commit, so other readers never see a
+// partial result. If the program exits (or is interrupted) first, the
+// temporary file is removed.
+type outputFile struct {
+	*os.File
+	path  string
+	force bool
+	once  sync.Once
+}
+
+// createOutputFile starts writing the file at path. Unless force is set it
+// refuses to replace an existing file.
+func createOutputFile(path string, force bool) (*outputFile, error) {
+	if err := checkClobber(path, force); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	f := &outputFile{File: tmp, path: path, force: force}
+	atExit(f.abort)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		exit(130)
+	}()
+	return f, nil
+}
+
+// checkClobber returns an error if path exists and force is not set.
+func checkClobber(path string, force bool) error {
+	if force {
+		return nil
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return errClobber(path)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// errClobber reports that path exists and may only be replaced with --force.
+func errClobber(path string) error {
+	return fmt.Errorf("%s already exists (use --force to overwrite it)", path)
+}
+
+// commit flushes the file to disk and moves it to its final path.
+func (f *outputFile) commit() error {
+	err := f.Sync()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0o644) // CreateTemp makes files private
+	}
+	if err == nil {
+		err = f.install()
+	}
+	if err != nil {
+		f.abort()
+		return err
+	}
+	f.once.Do(func() {}) // Nothing left to clean up
+	return nil
+}
+
+// link is os.Link, replaced in tests to act like a filesystem without
+// hard links.
+var link = os.Link
+
+// install moves the temporary file to its final path. Without force it
+// links rather than renames, so a file that appeared at the path while
+// results were being fetched makes it fail instead of being replaced.
+// Where hard links aren't supported it claims the path with an exclusively
+// created placeholder instead, and renames over that.
+func (f *outputFile) install() error {
+	if f.force {
+		return os.Rename(f.Name(), f.path)
+	}
+	err := link(f.Name(), f.path)
+	if errors.Is(err, fs.ErrExist) {
+		return errClobber(f.path)
+	}
+	if err == nil {
+		os.Remove(f.Name()) // The output is in place either way
+		return nil
+	}
+
+	placeholder, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return errClobber(f.path)
+	}
+	if err != nil {
+		return err
+	}
+	placeholder.Close()
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		os.Remove(f.path)
+		return err
+	}
+	return nil
+}
+
+// abort discards the temporary file. It is safe to call more than once.
+func (f *outputFile) abort() {
+	f.once.Do(func() {
+		f.Close()
+		os.Remove(f.Name())
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// noHardLinks makes link fail as it does on filesystems without hard links.
+func noHardLinks(t *testing.T) {
+	t.Helper()
+	link = func(oldname, newname string) error {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: syscall.ENOTSUP}
+	}
+	t.Cleanup(func() { link = os.Link })
+}
+
+func TestOutputFileCommit(t *testing.T) {
+	tests := []struct {
+		name     string
+		noLinks  bool
+		existing bool // Create the path while the output is being written
+		force    bool
+		wantErr  bool
+	}{
+		{name: "new"},
+		{name: "exists", existing: true, wantErr: true},
+		{name: "force", existing: true, force: true},
+		{name: "no hard links", noLinks: true},
+		{name: "no hard links, exists", noLinks: true, existing: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.noLinks {
+				noHardLinks(t)
+			}
+			dir := t.TempDir()
+			path := filepath.Join(dir, "out.json")
+
+			f, err := createOutputFile(path, tt.force)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.WriteString("new\n"); err != nil {
+				t.Fatal(err)
+			}
+			if tt.existing {
+				if err := os.WriteFile(path, []byte("theirs\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = f.commit()
+			want := "new\n"
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "already exists") {
+					t.Fatalf("commit() = %v, want an already exists error", err)
+				}
+				want = "theirs\n"
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != want {
+				t.Errorf("%s holds %q, want %q", path, data, want)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("temporary files left behind: %v", entries)
+			}
+		})
+	}
+}
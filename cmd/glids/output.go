@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"iter"
+	"os"
+
+	"github.com/bboles/glids/internal/display"
+	"github.com/bboles/glids/internal/gitlab"
+	"github.com/bboles/glids/internal/termui"
+)
+
+// newStdoutEncoder creates the encoder for opts.format on stdout, or the
+// --output-file if set.
+func newStdoutEncoder(opts runOptions) display.Encoder {
+	enc, err := display.NewEncoder(opts.out, opts.format, opts.encoding...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		exit(2)
+	}
+	return enc
+}
+
+// encodeLists writes sorted groups and projects to opts.out in opts.format.
+func encodeLists(opts runOptions, groups []gitlab.Group, projects []gitlab.Project) {
+	enc := newStdoutEncoder(opts)
+	for _, g := range groups {
+		encodeOrExit(enc.Group(g))
+	}
+	for _, p := range projects {
+		encodeOrExit(enc.Project(p))
+	}
+	encodeOrExit(enc.Close())
+}
+
+// encodeOrExit exits if writing output failed.
+func encodeOrExit(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nError writing output: %v\n", err)
+		exit(1)
+	}
+}
+
+// checkSort reports why key can't order the listing chosen by the mode
+// flags: groups in flat lists have no activity or size, and results printed
+// as they arrive (stream) must come from GitLab already in order.
+func checkSort(key gitlab.SortKey, stream, trees, groupsOnly, projectsOnly bool) error {
+	if trees {
+		if stream && key.NeedsPopulation() {
+			return fmt.Errorf("--stream prints each tree as soon as it is populated, so trees can't be sorted by %s", key)
+		}
+		return nil
+	}
+	if !projectsOnly && key.NeedsPopulation() {
+		return fmt.Errorf("--sort %s needs the projects beneath each group; use it with --hierarchy or --projects", key)
+	}
+	if !stream {
+		return nil
+	}
+	if !projectsOnly && !gitlab.GroupsInOrder(key) {
+		return fmt.Errorf("GitLab can't list groups by %s, so --stream can't print them in that order", key)
+	}
+	if !groupsOnly && !gitlab.ProjectsInOrder(key) {
+		return fmt.Errorf("GitLab can't list projects by %s, so --stream can't print them in that order", key)
+	}
+	return nil
+}
+
+// listGroups returns the group listing for opts. GitLab lists groups by
+// name unless, when only the first --limit matches are kept or results are
+// printed as they arrive, --sort asks for ID order.
+func listGroups(client *gitlab.Client, opts runOptions) iter.Seq2[gitlab.Group, error] {
+	if opts.limit > 0 || opts.stream {
+		return client.GroupsBy(opts.searchTerm, opts.allItems, opts.sort, opts.reverse)
+	}
+	return client.Groups(opts.searchTerm, opts.allItems)
+}
+
+// listProjects returns the project listing for opts. Fetching every match
+// uses GitLab's cheapest paging, in ID order; when only the first --limit
+// matches are kept, or results are printed as they arrive, GitLab is asked
+// for them in --sort order (most recently active first for path and size).
+// Sorting by size also needs each project's statistics, which only that
+// listing requests.
+func listProjects(client *gitlab.Client, opts runOptions) iter.Seq2[gitlab.Project, error] {
+	if opts.limit > 0 || opts.stream || opts.sort == gitlab.SortSize {
+		return client.ProjectsBy(opts.searchTerm, opts.allItems, opts.sort, opts.reverse)
+	}
+	return client.Projects(opts.searchTerm, opts.allItems)
+}
+
+// streamInto hands each item of seq to emit as soon as it arrives, printing
+// above the status line. It stops after limit items when limit is positive
+// and returns the number of items emitted.
+func streamInto[T any](seq iter.Seq2[T, error], limit int, status *termui.Status, emit func(T) error) (int, error) {
+	n := 0
+	for item, err := range seq {
+		if err != nil {
+			return n, err
+		}
+		var emitErr error
+		status.Above(func() { emitErr = emit(item) })
+		encodeOrExit(emitErr)
+		n++
+		if limit > 0 && n >= limit {
+			break
+		}
+	}
+	return n, nil
+}
+
+// exitOnFetchError reports a failed listing of resource and exits; a
+// cancelled confirmation exits cleanly. It does nothing if err is nil.
+func exitOnFetchError(err error, resource string) {
+	if err == nil {
+		return
+	}
+	if errors.Is(err, gitlab.ErrCancelled) {
+		fmt.Fprintf(os.Stderr, "\nOperation cancelled while fetching %s.\n", resource)
+		exit(0)
+	}
+	fmt.Fprintf(os.Stderr, "\nError getting %s: %v\n", resource, err)
+	exit(1)
+}
+
+// notice prints an informational message such as "No groups found". Text
+// output on stdout keeps it alongside the results; machine-readable formats
+// and output files are kept clean and it goes to stderr instead.
+func notice(opts runOptions, a ...any) {
+	if opts.format == display.FormatText && opts.outputPath == "" {
+		fmt.Println(a...)
+		return
+	}
+	fmt.Fprintln(os.Stderr, a...)
+}
+
+// isFlagSet reports whether the named flag was given on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/bboles/glids/internal/display"
+)
+
+// runReport implements "glids report": it fetches the matching groups and
+// projects, populates the hierarchy of each matching group and writes a
+// self-contained HTML page.
+func runReport(args []string) {
+	fs := flag.NewFlagSet(executableName+" report", flag.ExitOnError)
+	htmlPath := fs.String("html", "", "Write the HTML report to this file (required)")
+	force := fs.Bool("force", false, "Overwrite the --html file if it exists")
+	sf := addSelectFlags(fs)
+	conn := addConnFlags(fs, 0)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s report --html <file> [flags] [search]\n\n", executableName)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *htmlPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: --html is required")
+		fs.Usage()
+		os.Exit(2)
+	}
+	// The report always shows the trees of the matching groups next to the
+	// matches themselves
+	sel := sf.selection(fs)
+	sel.trees, sel.matches = true, true
+
+	file, err := createOutputFile(*htmlPath, *force)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	client, status := conn.setup()
+	defer status.Stop()
+	if !isDebug {
+		status.Start("Fetching groups and projects...")
+	}
+	inv, err := fetchInventory(client, sel, status)
+	status.Stop()
+	exitOnFetchError(err, "groups and projects")
+
+	report := display.Report{
+		GeneratedAt: time.Now(),
+		Filters:     sel.describe(),
+		Trees:       inv.trees,
+		Groups:      inv.groups,
+		Projects:    inv.projects,
+	}
+	if u, err := url.Parse(client.BaseURL()); err == nil {
+		report.Host = u.Host
+	}
+	if err := display.WriteHTMLReport(file, report); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing report:", err)
+		exit(1)
+	}
+	if err := file.commit(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing report:", err)
+		exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s (%d groups, %d projects matched)\n", *htmlPath, len(inv.groups), len(inv.projects))
+}
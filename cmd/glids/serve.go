@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bboles/glids/internal/gitlab"
+	"github.com/bboles/glids/internal/server"
+)
+
+// runServe implements "glids serve": a JSON lookup API over a cache of the
+// selected groups and projects, refreshed on an interval.
+func runServe(args []string) {
+	fs := flag.NewFlagSet(executableName+" serve", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "Address to listen on")
+	refresh := fs.Duration("refresh", 10*time.Minute, "How often to reload the cache from GitLab")
+	sel := addSelectFlags(fs)
+	conn := addConnFlags(fs, 3)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s serve [flags] [search]\n\n", executableName)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *refresh <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: --refresh must be positive")
+		os.Exit(2)
+	}
+
+	metrics := server.NewMetrics()
+	// Nobody is at a terminal to confirm large fetches
+	client, status := conn.setup(gitlab.WithConfirmFunc(nil), gitlab.WithObserver(metrics))
+	selection := sel.selection(fs)
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	srv := server.New(client, func() ([]gitlab.Group, []gitlab.Project, error) {
+		inv, err := fetchInventory(client, selection, status)
+		return inv.groups, inv.projects, err
+	}, server.WithLogger(logger), server.WithMetrics(metrics))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go srv.Run(ctx, *refresh)
+
+	httpServer := &http.Server{Addr: *listen, Handler: srv}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	logger.Printf("Listening on %s", *listen)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Printf("Error: %v", err)
+		os.Exit(1)
+	}
+}
@@ -1,4 +1,4 @@
-module glids
+module github.com/bboles/glids
 
 go 1.24.2
 
@@ -0,0 +1,173 @@
+package display
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+// Lang names a language accepted by "glids gen --lang".
+type Lang string
+
+const (
+	LangGo         Lang = "go"
+	LangTypeScript Lang = "typescript"
+	LangPython     Lang = "python"
+)
+
+// Langs lists every supported language, in the order shown in help text.
+var Langs = []Lang{LangGo, LangTypeScript, LangPython}
+
+// ParseLang returns the Lang named by s.
+func ParseLang(s string) (Lang, error) {
+	for _, l := range Langs {
+		if string(l) == strings.ToLower(s) {
+			return l, nil
+		}
+	}
+	names := make([]string, len(Langs))
+	for i, l := range Langs {
+		names[i] = string(l)
+	}
+	return "", fmt.Errorf("unknown language %q (want one of: %s)", s, strings.Join(names, ", "))
+}
+
+// Constants describes a generated source file of ID constants.
+type Constants struct {
+	Lang        Lang
+	Package     string // Go package name
+	Host        string // GitLab host the IDs came from, recorded in the header
+	GeneratedAt time.Time
+	Groups      []gitlab.Group
+	Projects    []gitlab.Project
+}
+
+// constant is one named ID.
+type constant struct {
+	name string
+	id   int
+	path string
+}
+
+// wordSplit matches runs of characters that separate words in a path.
+var wordSplit = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// constName returns the constant name for an item of kind at path in lang:
+// GroupPlatformTeams / ProjectPlatformTeamsApi in Go, and the same upper
+// snake case as EnvName (GROUP_PLATFORM_TEAMS) elsewhere.
+func constName(lang Lang, kind, path string) string {
+	if lang != LangGo {
+		return EnvName("", kind, path)
+	}
+	var b strings.Builder
+	for _, word := range wordSplit.Split(kind+"/"+path, -1) {
+		if word == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + strings.ToLower(word[1:]))
+	}
+	return b.String()
+}
+
+// constants returns the named IDs of kind, sorted by path so output is
+// stable between runs. Paths that map to the same name get the ID appended.
+func constants(lang Lang, kind string, items map[string]int) []constant {
+	paths := make([]string, 0, len(items))
+	for path := range items {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	used := make(map[string]bool)
+	consts := make([]constant, 0, len(paths))
+	for _, path := range paths {
+		name := constName(lang, kind, path)
+		if used[name] {
+			sep := "_"
+			if lang == LangGo {
+				sep = ""
+			}
+			name = fmt.Sprintf("%s%s%d", name, sep, items[path])
+		}
+		used[name] = true
+		consts = append(consts, constant{name: name, id: items[path], path: path})
+	}
+	return consts
+}
+
+// WriteConstants writes c as a source file of named ID constants, one block
+// for groups and one for projects. The header records the host and time.
+func WriteConstants(w io.Writer, c Constants) error {
+	if c.Lang == LangGo && !token.IsIdentifier(c.Package) {
+		return fmt.Errorf("invalid Go package name %q", c.Package)
+	}
+	groupIDs := make(map[string]int, len(c.Groups))
+	for _, g := range c.Groups {
+		groupIDs[g.FullPath] = g.ID
+	}
+	projectIDs := make(map[string]int, len(c.Projects))
+	for _, p := range c.Projects {
+		projectIDs[p.PathWithNamespace] = p.ID
+	}
+	blocks := []struct {
+		title  string
+		consts []constant
+	}{
+		{"Group IDs", constants(c.Lang, "group", groupIDs)},
+		{"Project IDs", constants(c.Lang, "project", projectIDs)},
+	}
+
+	comment := "//"
+	if c.Lang == LangPython {
+		comment = "#"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s Code generated by glids gen; DO NOT EDIT.\n", comment)
+	fmt.Fprintf(&buf, "%s Host: %s\n", comment, c.Host)
+	fmt.Fprintf(&buf, "%s Generated: %s\n", comment, c.GeneratedAt.UTC().Format(time.RFC3339))
+	if c.Lang == LangGo {
+		fmt.Fprintf(&buf, "\npackage %s\n", c.Package)
+	}
+	for _, block := range blocks {
+		if len(block.consts) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\n%s %s.\n", comment, block.title)
+		switch c.Lang {
+		case LangGo:
+			buf.WriteString("const (\n")
+			for _, k := range block.consts {
+				fmt.Fprintf(&buf, "\t%s = %d // %s\n", k.name, k.id, k.path)
+			}
+			buf.WriteString(")\n")
+		case LangTypeScript:
+			for _, k := range block.consts {
+				fmt.Fprintf(&buf, "export const %s = %d; // %s\n", k.name, k.id, k.path)
+			}
+		case LangPython:
+			for _, k := range block.consts {
+				fmt.Fprintf(&buf, "%s = %d  # %s\n", k.name, k.id, k.path)
+			}
+		default:
+			return fmt.Errorf("unknown language %q", c.Lang)
+		}
+	}
+
+	src := buf.Bytes()
+	if c.Lang == LangGo {
+		formatted, err := format.Source(src)
+		if err != nil {
+			return fmt.Errorf("formatting generated code: %w", err)
+		}
+		src = formatted
+	}
+	_, err := w.Write(src)
+	return err
+}
@@ -0,0 +1,91 @@
+package display
+
+import (
+	"strings"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+// style is a set of SGR parameters, e.g. "1;34" for bold blue.
+type style string
+
+const (
+	styleGroup     style = "1;34" // Bold blue
+	styleProject   style = "36"   // Cyan
+	styleID        style = "33"   // Yellow
+	stylePrivate   style = "35"   // Magenta, instead of the kind's colour
+	styleArchived  style = "2"    // Faint, instead of the kind's colour
+	styleMatch     style = "7"    // Reverse video, added to the base style
+	styleAnnotated style = "2"    // Faint, for statistics and other asides
+)
+
+// treeGlyphs are the connectors used to draw a tree.
+type treeGlyphs struct {
+	branch, corner, vertical, horizontal, space, marker string
+}
+
+var (
+	unicodeGlyphs = treeGlyphs{branch: "  ├", corner: "  └", vertical: "  │", horizontal: "──❯", space: " ", marker: "  ◀"}
+	asciiGlyphs   = treeGlyphs{branch: "  |", corner: "  `", vertical: "  |", horizontal: "-->", space: " ", marker: "  <"}
+)
+
+// painter applies styles when colour is enabled and highlights the search
+// term within paths and names.
+type painter struct {
+	color     bool
+	highlight string // Lower-cased search term; empty for none
+}
+
+func newPainter(cfg printConfig) painter {
+	return painter{color: cfg.color, highlight: strings.ToLower(cfg.highlight)}
+}
+
+// paint wraps text in s, or returns it unchanged without colour.
+func (p painter) paint(s style, text string) string {
+	if !p.color || s == "" || text == "" {
+		return text
+	}
+	return "\x1b[" + string(s) + "m" + text + "\x1b[0m"
+}
+
+// name paints a path or name in s, highlighting each case-insensitive
+// match of the search term.
+func (p painter) name(s style, text string) string {
+	if !p.color || p.highlight == "" {
+		return p.paint(s, text)
+	}
+	var b strings.Builder
+	lower := strings.ToLower(text)
+	for {
+		i := strings.Index(lower, p.highlight)
+		// Lower-casing can change byte lengths outside ASCII; don't risk
+		// splitting a rune and fall back to no highlighting
+		if i < 0 || len(lower) != len(text) {
+			b.WriteString(p.paint(s, text))
+			return b.String()
+		}
+		end := i + len(p.highlight)
+		b.WriteString(p.paint(s, text[:i]))
+		b.WriteString(p.paint(s+";"+styleMatch, text[i:end]))
+		text, lower = text[end:], lower[end:]
+	}
+}
+
+// groupStyle returns the style for g's name.
+func groupStyle(g gitlab.Group) style {
+	if g.Visibility == "private" {
+		return "1;" + stylePrivate
+	}
+	return styleGroup
+}
+
+// projectStyle returns the style for p's name. Archived wins over private.
+func projectStyle(p gitlab.Project) style {
+	switch {
+	case p.Archived:
+		return styleArchived
+	case p.Visibility == "private":
+		return stylePrivate
+	}
+	return styleProject
+}
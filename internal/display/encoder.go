@@ -0,0 +1,384 @@
+package display
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+// Format names an output format accepted by --output.
+type Format string
+
+const (
+	FormatText  Format = "text"  // Human-readable "path: id" lines and trees
+	FormatJSON  Format = "json"  // A single JSON array
+	FormatJSONL Format = "jsonl" // One JSON object per line (JSON Lines)
+	FormatCSV   Format = "csv"   // Comma-separated values with a header row
+
+	FormatMarkdown  Format = "markdown"  // Markdown tables, and nested bullet lists for hierarchies
+	FormatYAML      Format = "yaml"      // A single YAML sequence
+	FormatEnv       Format = "env"       // Shell "export NAME=ID" lines
+	FormatTerraform Format = "terraform" // Terraform import blocks and resource stubs
+
+	FormatDOT     Format = "dot"     // Graphviz digraph of a hierarchy
+	FormatMermaid Format = "mermaid" // Mermaid flowchart of a hierarchy
+)
+
+// Formats lists every supported output format, in the order shown in help text.
+var Formats = []Format{FormatText, FormatJSON, FormatJSONL, FormatCSV, FormatMarkdown, FormatYAML, FormatEnv, FormatTerraform, FormatDOT, FormatMermaid}
+
+// TreeOnly reports whether f can only encode hierarchies, not flat lists.
+func (f Format) TreeOnly() bool {
+	return f == FormatDOT || f == FormatMermaid
+}
+
+// ParseFormat returns the Format named by s.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == strings.ToLower(s) {
+			return f, nil
+		}
+	}
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("unknown output format %q (want one of: %s)", s, strings.Join(names, ", "))
+}
+
+// Record is the flat representation of a group or project used by the
+// machine-readable formats.
+type Record struct {
+	Kind     string `json:"kind"` // "group" or "project"
+	ID       int    `json:"id"`
+	Path     string `json:"path"`
+	Name     string `json:"name"`
+	ParentID *int   `json:"parent_id,omitempty"` // Parent group of a group, or the group containing a project
+}
+
+// TreeRecord is a group record with its populated children, used when
+// encoding hierarchies.
+type TreeRecord struct {
+	Record
+	Subgroups []TreeRecord `json:"subgroups,omitempty"`
+	Projects  []Record     `json:"projects,omitempty"`
+}
+
+// GroupRecord converts a group to its flat record.
+func GroupRecord(g gitlab.Group) Record {
+	return Record{Kind: "group", ID: g.ID, Path: g.FullPath, Name: g.Name, ParentID: g.ParentID}
+}
+
+// ProjectRecord converts a project to its flat record. ParentID is the
+// project's group, if it lives in one rather than a user namespace.
+func ProjectRecord(p gitlab.Project) Record {
+	rec := Record{Kind: "project", ID: p.ID, Path: p.PathWithNamespace, Name: p.Name}
+	if p.Namespace.Kind == "group" && p.Namespace.ID != 0 {
+		parentID := p.Namespace.ID
+		rec.ParentID = &parentID
+	}
+	return rec
+}
+
+// NewTreeRecord converts a populated group and its descendants to a tree record.
+func NewTreeRecord(g gitlab.Group) TreeRecord {
+	node := TreeRecord{Record: GroupRecord(g)}
+	for _, sub := range g.Subgroups {
+		node.Subgroups = append(node.Subgroups, NewTreeRecord(sub))
+	}
+	for _, p := range g.Projects {
+		rec := ProjectRecord(p)
+		rec.ParentID = &g.ID
+		node.Projects = append(node.Projects, rec)
+	}
+	return node
+}
+
+// Encoder writes groups, projects and hierarchies one at a time, so output
+// can be produced while results are still arriving. Close must be called to
+// finish the output (e.g. the closing brackets of a JSON document).
+type Encoder interface {
+	Group(g gitlab.Group) error
+	Project(p gitlab.Project) error
+	Tree(root gitlab.Group) error
+	Close() error
+}
+
+// EncoderOption configures optional behaviour of an Encoder. Options that
+// do not apply to a format are ignored.
+type EncoderOption func(*encoderConfig)
+
+type encoderConfig struct {
+	links     bool
+	envPrefix string
+	meta      *Metadata
+	text      []PrintOption
+}
+
+// Metadata describes how a set of results was produced.
+type Metadata struct {
+	Host        string    `json:"host"`
+	Filters     []string  `json:"filters,omitempty"` // Human-readable, as in the HTML report
+	GeneratedAt time.Time `json:"generated_at"`
+	Version     string    `json:"glids_version,omitempty"`
+}
+
+// WithLinks adds links to each item's GitLab page, for formats that support them.
+func WithLinks(enabled bool) EncoderOption {
+	return func(cfg *encoderConfig) {
+		cfg.links = enabled
+	}
+}
+
+// WithEnvPrefix sets the prefix of variable names written by the env
+// format (DefaultEnvPrefix if unset). It must be empty or a valid shell
+// identifier.
+func WithEnvPrefix(prefix string) EncoderOption {
+	return func(cfg *encoderConfig) {
+		cfg.envPrefix = prefix
+	}
+}
+
+// WithMetadata records meta with the output, for formats that can carry it
+// apart from the items: JSON output becomes an object with "metadata" and
+// "items" members instead of a bare array, and YAML starts with comment
+// lines. Other formats ignore it.
+func WithMetadata(meta *Metadata) EncoderOption {
+	return func(cfg *encoderConfig) {
+		cfg.meta = meta
+	}
+}
+
+// WithTextOptions passes opts (colour, ASCII trees, highlighting) to the
+// text format's lines and trees.
+func WithTextOptions(opts ...PrintOption) EncoderOption {
+	return func(cfg *encoderConfig) {
+		cfg.text = append(cfg.text, opts...)
+	}
+}
+
+// NewEncoder returns an Encoder writing format to w.
+func NewEncoder(w io.Writer, format Format, opts ...EncoderOption) (Encoder, error) {
+	cfg := encoderConfig{envPrefix: DefaultEnvPrefix}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	switch format {
+	case FormatText:
+		return &textEncoder{w: w, opts: cfg.text, paint: newPainter(newPrintConfig(cfg.text))}, nil
+	case FormatJSON:
+		return &jsonEncoder{w: w, meta: cfg.meta}, nil
+	case FormatJSONL:
+		return &jsonlEncoder{enc: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		return &csvEncoder{w: csv.NewWriter(w)}, nil
+	case FormatMarkdown:
+		return &markdownEncoder{w: w, links: cfg.links}, nil
+	case FormatYAML:
+		return &yamlEncoder{w: w, meta: cfg.meta}, nil
+	case FormatEnv:
+		if err := ValidateEnvPrefix(cfg.envPrefix); err != nil {
+			return nil, err
+		}
+		return newEnvEncoder(w, cfg.envPrefix), nil
+	case FormatTerraform:
+		return newTerraformEncoder(w), nil
+	case FormatDOT:
+		return &dotEncoder{w: w}, nil
+	case FormatMermaid:
+		return &mermaidEncoder{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// textEncoder writes unaligned "path: id" lines under "Groups:" and
+// "Projects:" headings, and trees in the PrintHierarchy layout.
+type textEncoder struct {
+	w            io.Writer
+	opts         []PrintOption
+	paint        painter
+	lastKind     string
+	wroteHeading bool
+}
+
+func (e *textEncoder) heading(kind, title string) error {
+	if e.lastKind == kind {
+		return nil
+	}
+	e.lastKind = kind
+	prefix := ""
+	if e.wroteHeading {
+		prefix = "\n"
+	}
+	e.wroteHeading = true
+	_, err := fmt.Fprintf(e.w, "%s%s:\n", prefix, title)
+	return err
+}
+
+func (e *textEncoder) Group(g gitlab.Group) error {
+	if err := e.heading("group", "Groups"); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(e.w, "%s: %s\n", e.paint.name(groupStyle(g), g.FullPath), e.paint.paint(styleID, strconv.Itoa(g.ID)))
+	return err
+}
+
+func (e *textEncoder) Project(p gitlab.Project) error {
+	if err := e.heading("project", "Projects"); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(e.w, "%s: %s\n", e.paint.name(projectStyle(p), p.PathWithNamespace), e.paint.paint(styleID, strconv.Itoa(p.ID)))
+	return err
+}
+
+func (e *textEncoder) Tree(root gitlab.Group) error {
+	return FprintHierarchy(e.w, root, e.opts...)
+}
+
+func (e *textEncoder) Close() error {
+	return nil
+}
+
+// jsonEncoder writes a JSON array of the groups, projects and trees, one
+// per line. With metadata set the array is the "items" member of an object
+// whose "metadata" member comes first.
+type jsonEncoder struct {
+	w     io.Writer
+	meta  *Metadata
+	begun bool
+	count int
+}
+
+// indent is the indentation of the array's elements.
+func (e *jsonEncoder) indent() string {
+	if e.meta != nil {
+		return "    "
+	}
+	return "  "
+}
+
+// begin writes the opening of the array, and of the object around it, once.
+func (e *jsonEncoder) begin() error {
+	if e.begun {
+		return nil
+	}
+	e.begun = true
+	if e.meta == nil {
+		_, err := io.WriteString(e.w, "[")
+		return err
+	}
+	data, err := json.Marshal(e.meta)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(e.w, "{\n  \"metadata\": "+string(data)+",\n  \"items\": [")
+	return err
+}
+
+func (e *jsonEncoder) write(v any) error {
+	if err := e.begin(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if e.count == 0 {
+		sep = "\n"
+	}
+	e.count++
+	_, err = fmt.Fprintf(e.w, "%s%s%s", sep, e.indent(), data)
+	return err
+}
+
+func (e *jsonEncoder) Group(g gitlab.Group) error     { return e.write(GroupRecord(g)) }
+func (e *jsonEncoder) Project(p gitlab.Project) error { return e.write(ProjectRecord(p)) }
+func (e *jsonEncoder) Tree(root gitlab.Group) error   { return e.write(NewTreeRecord(root)) }
+
+func (e *jsonEncoder) Close() error {
+	if err := e.begin(); err != nil {
+		return err
+	}
+	tail := "]"
+	if e.count > 0 {
+		tail = "\n" + e.indent()[2:] + tail // The closing bracket is one level out
+	}
+	if e.meta != nil {
+		tail += "\n}"
+	}
+	_, err := io.WriteString(e.w, tail+"\n")
+	return err
+}
+
+// jsonlEncoder writes one JSON object per line; trees are one nested object
+// per root. There is no room for metadata in the stream.
+type jsonlEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *jsonlEncoder) Group(g gitlab.Group) error     { return e.enc.Encode(GroupRecord(g)) }
+func (e *jsonlEncoder) Project(p gitlab.Project) error { return e.enc.Encode(ProjectRecord(p)) }
+func (e *jsonlEncoder) Tree(root gitlab.Group) error   { return e.enc.Encode(NewTreeRecord(root)) }
+func (e *jsonlEncoder) Close() error                   { return nil }
+
+// csvEncoder writes one row per group or project; trees are flattened
+// depth-first with parent_id linking each row to its group.
+type csvEncoder struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (e *csvEncoder) write(rec Record) error {
+	if !e.wroteHeader {
+		e.wroteHeader = true
+		if err := e.w.Write([]string{"kind", "id", "path", "name", "parent_id"}); err != nil {
+			return err
+		}
+	}
+	parentID := ""
+	if rec.ParentID != nil {
+		parentID = strconv.Itoa(*rec.ParentID)
+	}
+	if err := e.w.Write([]string{rec.Kind, strconv.Itoa(rec.ID), rec.Path, rec.Name, parentID}); err != nil {
+		return err
+	}
+	e.w.Flush() // Flush every row so streamed output appears immediately
+	return e.w.Error()
+}
+
+func (e *csvEncoder) Group(g gitlab.Group) error     { return e.write(GroupRecord(g)) }
+func (e *csvEncoder) Project(p gitlab.Project) error { return e.write(ProjectRecord(p)) }
+
+// Tree writes root, then its subgroups' trees, then its projects: the order
+// of the text tree.
+func (e *csvEncoder) Tree(root gitlab.Group) error {
+	if err := e.write(GroupRecord(root)); err != nil {
+		return err
+	}
+	for _, sub := range root.Subgroups {
+		if err := e.Tree(sub); err != nil {
+			return err
+		}
+	}
+	for _, p := range root.Projects {
+		rec := ProjectRecord(p)
+		rec.ParentID = &root.ID
+		if err := e.write(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
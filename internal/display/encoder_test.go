@@ -0,0 +1,168 @@
+package display
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+func TestJSON(t *testing.T) {
+	meta := &Metadata{
+		Host:        "gitlab.example.com",
+		Filters:     []string{"search: api"},
+		GeneratedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Version:     "v1.2.3",
+	}
+	group := gitlab.Group{ID: 1, Name: "platform", FullPath: "platform"}
+	project := gitlab.Project{ID: 10, Name: "api", PathWithNamespace: "platform/api"}
+
+	tests := []struct {
+		name  string
+		meta  *Metadata
+		items bool
+		want  string
+	}{
+		{"metadata", meta, true, `{
+  "metadata": {"host":"gitlab.example.com","filters":["search: api"],"generated_at":"2026-03-01T12:00:00Z","glids_version":"v1.2.3"},
+  "items": [
+    {"kind":"group","id":1,"path":"platform","name":"platform"},
+    {"kind":"project","id":10,"path":"platform/api","name":"api"}
+  ]
+}
+`},
+		{"metadata, empty", meta, false, `{
+  "metadata": {"host":"gitlab.example.com","filters":["search: api"],"generated_at":"2026-03-01T12:00:00Z","glids_version":"v1.2.3"},
+  "items": []
+}
+`},
+		// Without metadata the output is a bare array
+		{"array", nil, true, `[
+  {"kind":"group","id":1,"path":"platform","name":"platform"},
+  {"kind":"project","id":10,"path":"platform/api","name":"api"}
+]
+`},
+		{"empty array", nil, false, "[]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc, err := NewEncoder(&buf, FormatJSON, WithMetadata(tt.meta))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.items {
+				if err := enc.Group(group); err != nil {
+					t.Fatal(err)
+				}
+				if err := enc.Project(project); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+
+			if tt.meta == nil {
+				var items []Record
+				if err := json.Unmarshal(buf.Bytes(), &items); err != nil {
+					t.Fatalf("output is not a JSON array: %v", err)
+				}
+				return
+			}
+			var doc struct {
+				Metadata *Metadata `json:"metadata"`
+				Items    []Record  `json:"items"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatalf("output is not valid JSON: %v", err)
+			}
+			if doc.Metadata == nil || doc.Items == nil {
+				t.Errorf("decoded %+v", doc)
+			}
+		})
+	}
+}
+
+func TestJSONLinesHasNoMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, FormatJSONL, WithMetadata(&Metadata{Host: "gitlab.example.com"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Project(gitlab.Project{ID: 10, Name: "api", PathWithNamespace: "platform/api"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"kind":"project","id":10,"path":"platform/api","name":"api"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestYAMLString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"platform/teams/api", "platform/teams/api"},
+		{"My Project", "My Project"},
+		{".github", ".github"},
+		{"yes", `"yes"`},
+		{"Null", `"Null"`},
+		{"42", `"42"`},
+		{"trailing ", `"trailing "`},
+		{".5", `".5"`},
+		{".5rc1", `".5rc1"`},
+		{"-.5", `"-.5"`},
+		{".inf", `".inf"`},
+		{".Inf", `".Inf"`},
+		{".INF", `".INF"`},
+		{"-.inf", `"-.inf"`},
+		{"+.inf", `"+.inf"`},
+		{".nan", `".nan"`},
+		{".NaN", `".NaN"`},
+		{".NAN", `".NAN"`},
+		{".info", ".info"},
+	}
+	for _, tt := range tests {
+		if got := yamlString(tt.in); got != tt.want {
+			t.Errorf("yamlString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestYAMLQuotesNumberLikeNames(t *testing.T) {
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Group(gitlab.Group{ID: 1, Name: ".NaN", FullPath: ".nan"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Project(gitlab.Project{ID: 10, Name: ".5", PathWithNamespace: ".nan/.5"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `- kind: group
+  id: 1
+  path: ".nan"
+  name: ".NaN"
+- kind: project
+  id: 10
+  path: .nan/.5
+  name: ".5"
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
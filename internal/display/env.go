@@ -0,0 +1,109 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+// DefaultEnvPrefix is the prefix of variable names written by --output env.
+const DefaultEnvPrefix = "GL_"
+
+// envPrefixPattern matches prefixes that keep variable names valid in POSIX shells.
+var envPrefixPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// ValidateEnvPrefix reports an error unless prefix is empty or a valid shell identifier.
+func ValidateEnvPrefix(prefix string) error {
+	if !envPrefixPattern.MatchString(prefix) {
+		return fmt.Errorf("invalid variable prefix %q: use letters, digits and underscores, not starting with a digit", prefix)
+	}
+	return nil
+}
+
+// envUnsafe matches runs of characters that may not appear in a variable name.
+var envUnsafe = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// EnvName returns the shell variable name for an item of kind ("group" or
+// "project") at path, e.g. GL_PROJECT_PLATFORM_TEAMS_API. The path is
+// upper-cased and every run of other characters becomes a single underscore,
+// so the same path always yields the same name. Different paths can share a
+// name (teams-api and teams_api), and a path with no letters or digits in
+// ASCII yields just the prefix and kind; the env encoder appends IDs to
+// tell them apart.
+func EnvName(prefix, kind, path string) string {
+	name := prefix + strings.ToUpper(kind)
+	if sanitized := strings.Trim(envUnsafe.ReplaceAllString(strings.ToUpper(path), "_"), "_"); sanitized != "" {
+		name += "_" + sanitized
+	}
+	return name
+}
+
+// envEncoder writes "export NAME=ID" lines that a shell can eval.
+type envEncoder struct {
+	w      io.Writer
+	prefix string
+	names  map[string]bool // Variable names written so far
+	wrote  map[string]bool // Items written so far, by kind and ID
+}
+
+func newEnvEncoder(w io.Writer, prefix string) *envEncoder {
+	return &envEncoder{
+		w:      w,
+		prefix: prefix,
+		names:  make(map[string]bool),
+		wrote:  make(map[string]bool),
+	}
+}
+
+// name returns a unique variable name for an item of kind at path, appending
+// the ID if the path has no usable characters or another path already
+// sanitized to the same name.
+func (e *envEncoder) name(kind, path string, id int) string {
+	name := EnvName(e.prefix, kind, path)
+	if e.names[name] || name == EnvName(e.prefix, kind, "") {
+		name += "_" + strconv.Itoa(id)
+	}
+	e.names[name] = true
+	return name
+}
+
+func (e *envEncoder) export(kind, path string, id int) error {
+	key := kind + ":" + strconv.Itoa(id)
+	if e.wrote[key] {
+		return nil // Overlapping trees repeat items; export each once
+	}
+	e.wrote[key] = true
+	_, err := fmt.Fprintf(e.w, "export %s=%d\n", e.name(kind, path, id), id)
+	return err
+}
+
+func (e *envEncoder) Group(g gitlab.Group) error { return e.export("group", g.FullPath, g.ID) }
+func (e *envEncoder) Project(p gitlab.Project) error {
+	return e.export("project", p.PathWithNamespace, p.ID)
+}
+
+// Tree flattens root depth-first, like the CSV encoder.
+func (e *envEncoder) Tree(root gitlab.Group) error {
+	if err := e.Group(root); err != nil {
+		return err
+	}
+	for _, p := range root.Projects {
+		if err := e.Project(p); err != nil {
+			return err
+		}
+	}
+	for _, sub := range root.Subgroups {
+		if err := e.Tree(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *envEncoder) Close() error {
+	return nil
+}
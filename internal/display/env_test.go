@@ -0,0 +1,65 @@
+package display
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		kind, path, want string
+	}{
+		{"project", "platform/teams/api", "GL_PROJECT_PLATFORM_TEAMS_API"},
+		{"group", "platform/teams-api", "GL_GROUP_PLATFORM_TEAMS_API"},
+		{"project", "--platform.api--", "GL_PROJECT_PLATFORM_API"},
+		{"project", "日本/語", "GL_PROJECT"},
+	}
+	for _, tt := range tests {
+		if got := EnvName(DefaultEnvPrefix, tt.kind, tt.path); got != tt.want {
+			t.Errorf("EnvName(%q, %q) = %q, want %q", tt.kind, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestEnvEncoderUniqueNames(t *testing.T) {
+	platform := 1
+	root := gitlab.Group{
+		ID: platform, FullPath: "platform",
+		Projects: []gitlab.Project{
+			{ID: 10, PathWithNamespace: "platform/teams-api"},
+			{ID: 11, PathWithNamespace: "platform/teams_api"},
+			{ID: 12, PathWithNamespace: "platform/teams.api"},
+			{ID: 13, PathWithNamespace: "日本"},
+		},
+		Subgroups: []gitlab.Group{{ID: 2, ParentID: &platform, FullPath: "platform/teams"}},
+	}
+
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, FormatEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A nested group matched on its own repeats part of the first tree
+	if err := enc.Tree(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Tree(root.Subgroups[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `export GL_GROUP_PLATFORM=1
+export GL_PROJECT_PLATFORM_TEAMS_API=10
+export GL_PROJECT_PLATFORM_TEAMS_API_11=11
+export GL_PROJECT_PLATFORM_TEAMS_API_12=12
+export GL_PROJECT_13=13
+export GL_GROUP_PLATFORM_TEAMS=2
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+package display
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bboles/glids/internal/gitlab"
+	"github.com/bboles/glids/internal/gitlabtest"
+)
+
+// fetchTree populates platform from a fake GitLab holding platform/api,
+// platform/teams/web and platform/teams/backend/worker, with fail (if not
+// empty) answering 500 for good. It returns the populated root and the
+// error PopulateHierarchy reported.
+func fetchTree(t *testing.T, fail string) (gitlab.Group, error) {
+	t.Helper()
+	fake := gitlabtest.NewServer()
+	t.Cleanup(fake.Close)
+	platform, teams, backend := 1, 2, 3
+	fake.AddGroup(
+		gitlab.Group{ID: platform, Name: "platform", FullPath: "platform"},
+		gitlab.Group{ID: teams, ParentID: &platform, Name: "teams", FullPath: "platform/teams"},
+		gitlab.Group{ID: backend, ParentID: &teams, Name: "backend", FullPath: "platform/teams/backend"},
+	)
+	fake.AddProject(
+		gitlab.Project{ID: 10, Name: "api", PathWithNamespace: "platform/api", Namespace: gitlab.Namespace{ID: platform, Kind: "group"}},
+		gitlab.Project{ID: 20, Name: "web", PathWithNamespace: "platform/teams/web", Namespace: gitlab.Namespace{ID: teams, Kind: "group"}},
+		gitlab.Project{ID: 30, Name: "worker", PathWithNamespace: "platform/teams/backend/worker", Namespace: gitlab.Namespace{ID: backend, Kind: "group"}},
+	)
+	if fail != "" {
+		fake.Fail(fail, http.StatusInternalServerError, -1)
+	}
+	client := fake.Client()
+	root, err := client.GetGroup("platform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.PopulateHierarchy(&root, gitlab.HierarchyOptions{AllItems: true})
+	return root, err
+}
+
+func TestEncodeFetchedTree(t *testing.T) {
+	root, err := fetchTree(t, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{FormatCSV, `kind,id,path,name,parent_id
+group,1,platform,platform,
+group,2,platform/teams,teams,1
+group,3,platform/teams/backend,backend,2
+project,30,platform/teams/backend/worker,worker,3
+project,20,platform/teams/web,web,2
+project,10,platform/api,api,1
+`},
+		{FormatJSONL, `{"kind":"group","id":1,"path":"platform","name":"platform","subgroups":[{"kind":"group","id":2,"path":"platform/teams","name":"teams","parent_id":1,"subgroups":[{"kind":"group","id":3,"path":"platform/teams/backend","name":"backend","parent_id":2,"projects":[{"kind":"project","id":30,"path":"platform/teams/backend/worker","name":"worker","parent_id":3}]}],"projects":[{"kind":"project","id":20,"path":"platform/teams/web","name":"web","parent_id":2}]}],"projects":[{"kind":"project","id":10,"path":"platform/api","name":"api","parent_id":1}]}
+`},
+		{FormatEnv, `export GL_GROUP_PLATFORM=1
+export GL_PROJECT_PLATFORM_API=10
+export GL_GROUP_PLATFORM_TEAMS=2
+export GL_PROJECT_PLATFORM_TEAMS_WEB=20
+export GL_GROUP_PLATFORM_TEAMS_BACKEND=3
+export GL_PROJECT_PLATFORM_TEAMS_BACKEND_WORKER=30
+`},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		enc, err := NewEncoder(&buf, tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Tree(root); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestPrintPartialTree(t *testing.T) {
+	// The teams group's projects can't be listed, but its subgroups can
+	root, err := fetchTree(t, "/groups/2/projects")
+	if err == nil {
+		t.Fatal("PopulateHierarchy succeeded despite a failing listing")
+	}
+
+	var buf bytes.Buffer
+	if err := FprintHierarchy(&buf, root, WithASCII(true)); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"platform (ID: 1)", "teams [G] [ID=2]", "backend [G] [ID=3]", "worker [P] [ID=30]", "api [P] [ID=10]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("tree lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "web") {
+		t.Errorf("tree shows a project whose listing failed:\n%s", out)
+	}
+}
@@ -0,0 +1,209 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+// visibilityColors are the fill colours used for each GitLab visibility level
+// in graph output. Unknown visibilities are left unfilled.
+var visibilityColors = map[string]string{
+	"private":  "#f8d7da",
+	"internal": "#fff3cd",
+	"public":   "#d4edda",
+}
+
+// archivedColor is the text and border colour of archived projects.
+const archivedColor = "#888888"
+
+// graphWalker visits every group and project of one or more trees once,
+// so trees whose roots are nested inside earlier trees are not drawn twice.
+type graphWalker struct {
+	seen map[string]bool
+}
+
+// walk calls group for every group and project for every project beneath
+// root that has not been visited before. parent is the ID of the node's
+// group, or "" for the root.
+func (gw *graphWalker) walk(root gitlab.Group, group func(g gitlab.Group, id, parent string) error, project func(p gitlab.Project, id, parent string) error) error {
+	if gw.seen == nil {
+		gw.seen = make(map[string]bool)
+	}
+	var visit func(g gitlab.Group, parent string) error
+	visit = func(g gitlab.Group, parent string) error {
+		id := fmt.Sprintf("g%d", g.ID)
+		if !gw.seen[id] {
+			gw.seen[id] = true
+			if err := group(g, id, parent); err != nil {
+				return err
+			}
+		}
+		for _, sub := range g.Subgroups {
+			if err := visit(sub, id); err != nil {
+				return err
+			}
+		}
+		for _, p := range g.Projects {
+			pid := fmt.Sprintf("p%d", p.ID)
+			if gw.seen[pid] {
+				continue
+			}
+			gw.seen[pid] = true
+			if err := project(p, pid, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return visit(root, "")
+}
+
+// errTreeOnly is returned when a graph encoder is given a flat list.
+func errTreeOnly(format Format) error {
+	return fmt.Errorf("%s output is only available with --hierarchy", format)
+}
+
+// dotEncoder writes trees as a Graphviz digraph: groups are folder nodes,
+// projects are box leaves, and edges run from each group to its children.
+type dotEncoder struct {
+	w           io.Writer
+	walker      graphWalker
+	wroteHeader bool
+}
+
+// dotQuote quotes s as a DOT string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func (e *dotEncoder) header() error {
+	if e.wroteHeader {
+		return nil
+	}
+	e.wroteHeader = true
+	_, err := fmt.Fprint(e.w, "digraph glids {\n  rankdir=LR;\n  node [fontname=\"Helvetica\", style=filled, fillcolor=white];\n")
+	return err
+}
+
+func (e *dotEncoder) Group(gitlab.Group) error     { return errTreeOnly(FormatDOT) }
+func (e *dotEncoder) Project(gitlab.Project) error { return errTreeOnly(FormatDOT) }
+
+func (e *dotEncoder) Tree(root gitlab.Group) error {
+	if err := e.header(); err != nil {
+		return err
+	}
+	return e.walker.walk(root, func(g gitlab.Group, id, parent string) error {
+		name := g.Name
+		if parent == "" {
+			name = g.FullPath // Roots show their full path, as in PrintHierarchy
+		}
+		attrs := fmt.Sprintf("shape=folder, label=%s", dotQuote(fmt.Sprintf("%s\n[ID=%d]", name, g.ID)))
+		if color, ok := visibilityColors[g.Visibility]; ok {
+			attrs += ", fillcolor=" + dotQuote(color)
+		}
+		return e.node(id, attrs, parent)
+	}, func(p gitlab.Project, id, parent string) error {
+		attrs := fmt.Sprintf("shape=box, label=%s", dotQuote(fmt.Sprintf("%s\n[ID=%d]", p.Name, p.ID)))
+		if color, ok := visibilityColors[p.Visibility]; ok {
+			attrs += ", fillcolor=" + dotQuote(color)
+		}
+		if p.Archived {
+			attrs += fmt.Sprintf(", style=\"filled,dashed\", color=%s, fontcolor=%s", dotQuote(archivedColor), dotQuote(archivedColor))
+		}
+		return e.node(id, attrs, parent)
+	})
+}
+
+// node writes a node statement and, unless it is a root, the edge from its parent.
+func (e *dotEncoder) node(id, attrs, parent string) error {
+	if _, err := fmt.Fprintf(e.w, "  %s [%s];\n", id, attrs); err != nil {
+		return err
+	}
+	if parent == "" {
+		return nil
+	}
+	_, err := fmt.Fprintf(e.w, "  %s -> %s;\n", parent, id)
+	return err
+}
+
+func (e *dotEncoder) Close() error {
+	if err := e.header(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(e.w, "}")
+	return err
+}
+
+// mermaidEncoder writes trees as a Mermaid flowchart, with classes for each
+// visibility level and for archived projects.
+type mermaidEncoder struct {
+	w           io.Writer
+	walker      graphWalker
+	wroteHeader bool
+}
+
+// mermaidLabel quotes s as a Mermaid node label.
+func mermaidLabel(s string) string {
+	return `"` + strings.NewReplacer(`"`, "#quot;", "\n", "<br/>").Replace(s) + `"`
+}
+
+func (e *mermaidEncoder) header() error {
+	if e.wroteHeader {
+		return nil
+	}
+	e.wroteHeader = true
+	if _, err := fmt.Fprintln(e.w, "flowchart LR"); err != nil {
+		return err
+	}
+	for _, vis := range []string{"private", "internal", "public"} {
+		if _, err := fmt.Fprintf(e.w, "  classDef %s fill:%s\n", vis, visibilityColors[vis]); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(e.w, "  classDef archived color:%s,stroke:%s,stroke-dasharray:5 5\n", archivedColor, archivedColor)
+	return err
+}
+
+func (e *mermaidEncoder) Group(gitlab.Group) error     { return errTreeOnly(FormatMermaid) }
+func (e *mermaidEncoder) Project(gitlab.Project) error { return errTreeOnly(FormatMermaid) }
+
+func (e *mermaidEncoder) Tree(root gitlab.Group) error {
+	if err := e.header(); err != nil {
+		return err
+	}
+	return e.walker.walk(root, func(g gitlab.Group, id, parent string) error {
+		name := g.Name
+		if parent == "" {
+			name = g.FullPath
+		}
+		shape := "[" + mermaidLabel(fmt.Sprintf("%s\n[ID=%d]", name, g.ID)) + "]"
+		return e.node(id, shape, g.Visibility, parent)
+	}, func(p gitlab.Project, id, parent string) error {
+		shape := "(" + mermaidLabel(fmt.Sprintf("%s\n[ID=%d]", p.Name, p.ID)) + ")"
+		class := p.Visibility
+		if p.Archived {
+			class = "archived"
+		}
+		return e.node(id, shape, class, parent)
+	})
+}
+
+// node writes a node, with its class if known, linked from its parent unless it is a root.
+func (e *mermaidEncoder) node(id, shape, class, parent string) error {
+	stmt := id + shape
+	if class == "archived" || visibilityColors[class] != "" {
+		stmt += ":::" + class
+	}
+	if parent != "" {
+		stmt = parent + " --> " + stmt
+	}
+	_, err := fmt.Fprintf(e.w, "  %s\n", stmt)
+	return err
+}
+
+func (e *mermaidEncoder) Close() error {
+	return e.header()
+}
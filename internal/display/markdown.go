@@ -0,0 +1,123 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+// markdownEncoder writes GitLab/GitHub-flavoured markdown: a table under a
+// "Groups" or "Projects" heading for lists, and nested bullet lists for trees.
+type markdownEncoder struct {
+	w        io.Writer
+	links    bool // Add a link column (or link names in trees) to web URLs
+	lastKind string
+	wrote    bool
+}
+
+// markdownEscape escapes characters that would break a table cell or
+// start markdown formatting.
+var markdownEscape = strings.NewReplacer(`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`)
+
+// markdownLink returns text linked to url, or just the escaped text if url is empty.
+func markdownLink(text, url string) string {
+	if url == "" {
+		return markdownEscape.Replace(text)
+	}
+	return "[" + markdownEscape.Replace(text) + "](" + url + ")"
+}
+
+// separate writes a blank line between blocks.
+func (e *markdownEncoder) separate() error {
+	if !e.wrote {
+		e.wrote = true
+		return nil
+	}
+	_, err := fmt.Fprintln(e.w)
+	return err
+}
+
+// table starts a new heading and table when the kind of row changes.
+func (e *markdownEncoder) table(kind, title string) error {
+	if e.lastKind == kind {
+		return nil
+	}
+	e.lastKind = kind
+	if err := e.separate(); err != nil {
+		return err
+	}
+	header := "| Path | Name | ID |\n| --- | --- | ---: |\n"
+	if e.links {
+		header = "| Path | Name | ID | Link |\n| --- | --- | ---: | --- |\n"
+	}
+	_, err := fmt.Fprintf(e.w, "### %s\n\n%s", title, header)
+	return err
+}
+
+func (e *markdownEncoder) row(path, name string, id int, url string) error {
+	line := fmt.Sprintf("| %s | %s | %d |", markdownEscape.Replace(path), markdownEscape.Replace(name), id)
+	if e.links {
+		link := ""
+		if url != "" {
+			link = markdownLink("open", url)
+		}
+		line += " " + link + " |"
+	}
+	_, err := fmt.Fprintln(e.w, line)
+	return err
+}
+
+func (e *markdownEncoder) Group(g gitlab.Group) error {
+	if err := e.table("group", "Groups"); err != nil {
+		return err
+	}
+	return e.row(g.FullPath, g.Name, g.ID, g.WebURL)
+}
+
+func (e *markdownEncoder) Project(p gitlab.Project) error {
+	if err := e.table("project", "Projects"); err != nil {
+		return err
+	}
+	return e.row(p.PathWithNamespace, p.Name, p.ID, p.WebURL)
+}
+
+// Tree writes root as a bullet with its subgroups and projects nested beneath.
+func (e *markdownEncoder) Tree(root gitlab.Group) error {
+	e.lastKind = "" // A following list starts a fresh table
+	if err := e.separate(); err != nil {
+		return err
+	}
+	return e.bullets(root, root.FullPath, "")
+}
+
+func (e *markdownEncoder) bullets(g gitlab.Group, name, indent string) error {
+	if _, err := fmt.Fprintf(e.w, "%s- **%s** (group, ID %d)\n", indent, e.name(name, g.WebURL), g.ID); err != nil {
+		return err
+	}
+	childIndent := indent + "  "
+	for _, sub := range g.Subgroups {
+		if err := e.bullets(sub, sub.Name, childIndent); err != nil {
+			return err
+		}
+	}
+	for _, p := range g.Projects {
+		if _, err := fmt.Fprintf(e.w, "%s- %s (project, ID %d)\n", childIndent, e.name(p.Name, p.WebURL), p.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// name returns name, linked to url when links are enabled.
+func (e *markdownEncoder) name(name, url string) string {
+	if !e.links {
+		url = ""
+	}
+	return markdownLink(name, url)
+}
+
+func (e *markdownEncoder) Close() error {
+	return nil
+}
@@ -2,84 +2,314 @@ package display
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"text/tabwriter"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
-	"glids/internal/gitlab"
+	"github.com/bboles/glids/internal/gitlab"
 )
 
-const (
-	treeBranch     = "  ├"
-	treeCorner     = "  └"
-	treeVertical   = "  │"
-	treeHorizontal = "──❯"
-	treeSpace      = " "
-)
+// PrintOption configures the human-readable list and tree printers.
+type PrintOption func(*printConfig)
+
+type printConfig struct {
+	width     int    // Minimum width of the path column in lists; 0 auto-sizes
+	stats     bool   // Annotate tree groups with statistics and summarise the root
+	color     bool   // Style output with ANSI escape sequences
+	ascii     bool   // Draw trees with ASCII characters only
+	highlight string // Search term to highlight in paths and names
+}
+
+// WithWidth sets the minimum width of the path column in lists. 0, the
+// default, sizes the column to the longest path.
+func WithWidth(width int) PrintOption {
+	return func(cfg *printConfig) {
+		cfg.width = width
+	}
+}
+
+// WithStats annotates each group in a tree with its gitlab.GroupStats and
+// ends the tree with a summary for the root.
+func WithStats(enabled bool) PrintOption {
+	return func(cfg *printConfig) {
+		cfg.stats = enabled
+	}
+}
+
+// WithColor styles groups, projects, IDs and archived or private items
+// with ANSI escape sequences. Callers decide whether the output is a
+// terminal that wants colour.
+func WithColor(enabled bool) PrintOption {
+	return func(cfg *printConfig) {
+		cfg.color = enabled
+	}
+}
+
+// WithASCII draws trees with plain ASCII connectors, for terminals and
+// fonts that can't render box-drawing characters.
+func WithASCII(enabled bool) PrintOption {
+	return func(cfg *printConfig) {
+		cfg.ascii = enabled
+	}
+}
+
+// WithHighlight highlights case-insensitive matches of term in paths and
+// names. It has no effect without WithColor.
+func WithHighlight(term string) PrintOption {
+	return func(cfg *printConfig) {
+		cfg.highlight = term
+	}
+}
+
+func newPrintConfig(opts []PrintOption) printConfig {
+	var cfg printConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// FprintProjectList writes projects to w as right-aligned "path: id" lines.
+func FprintProjectList(w io.Writer, projects []gitlab.Project, opts ...PrintOption) error {
+	entries := make([]listEntry, len(projects))
+	for i, project := range projects {
+		entries[i] = listEntry{path: project.PathWithNamespace, id: project.ID, style: projectStyle(project)}
+	}
+	return fprintList(w, entries, newPrintConfig(opts))
+}
+
+// FprintGroupList writes groups to w as right-aligned "path: id" lines.
+func FprintGroupList(w io.Writer, groups []gitlab.Group, opts ...PrintOption) error {
+	entries := make([]listEntry, len(groups))
+	for i, group := range groups {
+		entries[i] = listEntry{path: group.FullPath, id: group.ID, style: groupStyle(group)}
+	}
+	return fprintList(w, entries, newPrintConfig(opts))
+}
+
+// FprintLists writes groups and projects to w under "Groups:" and
+// "Projects:" headings, with the ID columns of both lists aligned. An empty
+// list is left out, heading and all.
+func FprintLists(w io.Writer, groups []gitlab.Group, projects []gitlab.Project, opts ...PrintOption) error {
+	width := 0
+	for _, g := range groups {
+		width = max(width, utf8.RuneCountInString(g.FullPath)+3) // Colon and padding
+	}
+	for _, p := range projects {
+		width = max(width, utf8.RuneCountInString(p.PathWithNamespace)+3)
+	}
+	opts = append(opts[:len(opts):len(opts)], WithWidth(width))
+
+	if len(groups) > 0 {
+		if _, err := io.WriteString(w, "\nGroups:\n"); err != nil {
+			return err
+		}
+		if err := FprintGroupList(w, groups, opts...); err != nil {
+			return err
+		}
+	}
+	if len(projects) > 0 {
+		if _, err := io.WriteString(w, "\nProjects:\n"); err != nil {
+			return err
+		}
+		return FprintProjectList(w, projects, opts...)
+	}
+	return nil
+}
+
+// listEntry is one line of a group or project list.
+type listEntry struct {
+	path  string
+	id    int
+	style style
+}
+
+// fprintList writes entries with their paths right-aligned in a column of
+// at least cfg.width, laid out as a tabwriter with two spaces of padding
+// would. Alignment is done here because a tabwriter would count colour
+// escape sequences as text.
+func fprintList(w io.Writer, entries []listEntry, cfg printConfig) error {
+	width := cfg.width
+	for _, e := range entries {
+		width = max(width, utf8.RuneCountInString(e.path)+3) // Colon and padding
+	}
+	p := newPainter(cfg)
+	var b strings.Builder
+	for _, e := range entries {
+		pad := strings.Repeat(" ", width-utf8.RuneCountInString(e.path)-1)
+		fmt.Fprintf(&b, "%s%s:%s\n", pad, p.name(e.style, e.path), p.paint(styleID, fmt.Sprintf("%6d", e.id)))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// FprintHierarchy writes rootGroup and its populated descendants to w as a tree.
+func FprintHierarchy(w io.Writer, rootGroup gitlab.Group, opts ...PrintOption) error {
+	tp := newTreePrinter(w, opts)
+	tp.print(rootGroup)
+	return tp.err
+}
 
-// PrintProjectList prints a list of projects using tabwriter.
+// FprintAncestors writes the chain of groups from root down to the target
+// item to w, in the same layout as FprintHierarchy, marking the target.
+// target identifies the item by kind ("group" or "project") and ID.
+func FprintAncestors(w io.Writer, root gitlab.Group, target Record, opts ...PrintOption) error {
+	tp := newTreePrinter(w, opts)
+	tp.marked = &target
+	tp.print(root)
+	return tp.err
+}
+
+// PrintProjectList prints a list of projects on stdout.
 // nameWidth is the desired minimum width for the project path column. If 0, tabwriter auto-sizes.
 func PrintProjectList(projects []gitlab.Project, nameWidth int) {
-	// Use nameWidth as minwidth, and set AlignRight flag for tabwriter
-	w := tabwriter.NewWriter(os.Stdout, nameWidth, 0, 2, ' ', tabwriter.AlignRight)
-	defer w.Flush()
-	for _, project := range projects {
-		displayName := project.PathWithNamespace + ":"
-		// Pass displayName directly; tabwriter handles alignment and padding based on minwidth
-		fmt.Fprintf(w, "%s\t%6d\n", displayName, project.ID)
-	}
+	FprintProjectList(os.Stdout, projects, WithWidth(nameWidth))
 }
 
-// PrintGroupList prints a list of groups using tabwriter.
+// PrintGroupList prints a list of groups on stdout.
 // nameWidth is the desired minimum width for the group path column. If 0, tabwriter auto-sizes.
 func PrintGroupList(groups []gitlab.Group, nameWidth int) {
-	// Use nameWidth as minwidth, and set AlignRight flag for tabwriter
-	w := tabwriter.NewWriter(os.Stdout, nameWidth, 0, 2, ' ', tabwriter.AlignRight)
-	defer w.Flush()
-	for _, group := range groups {
-		displayName := group.FullPath + ":"
-		// Pass displayName directly; tabwriter handles alignment and padding based on minwidth
-		fmt.Fprintf(w, "%s\t%6d\n", displayName, group.ID)
-	}
+	FprintGroupList(os.Stdout, groups, WithWidth(nameWidth))
 }
 
-// PrintHierarchy prints the full hierarchy starting from a root group.
+// PrintHierarchy prints the full hierarchy starting from a root group on stdout.
 func PrintHierarchy(rootGroup gitlab.Group) {
-	fmt.Printf("\n%s (ID: %d)\n", rootGroup.FullPath, rootGroup.ID) // Print the root group path itself
+	FprintHierarchy(os.Stdout, rootGroup)
+}
+
+// PrintHierarchyStats prints the hierarchy like PrintHierarchy, annotating
+// each group with its gitlab.GroupStats and ending with a summary for the root.
+func PrintHierarchyStats(rootGroup gitlab.Group) {
+	FprintHierarchy(os.Stdout, rootGroup, WithStats(true))
+}
+
+// PrintAncestors prints the result of FprintAncestors on stdout.
+func PrintAncestors(root gitlab.Group, target Record) {
+	FprintAncestors(os.Stdout, root, target)
+}
+
+// treePrinter writes a group tree with box-drawing connectors. The first
+// write error is kept in err and later writes are skipped.
+type treePrinter struct {
+	w      io.Writer
+	cfg    printConfig
+	glyphs treeGlyphs
+	paint  painter
+	marked *Record // Node to flag with the marker glyph, if any
+	err    error
+}
+
+func newTreePrinter(w io.Writer, opts []PrintOption) *treePrinter {
+	cfg := newPrintConfig(opts)
+	tp := &treePrinter{w: w, cfg: cfg, glyphs: unicodeGlyphs, paint: newPainter(cfg)}
+	if cfg.ascii {
+		tp.glyphs = asciiGlyphs
+	}
+	return tp
+}
+
+// printf writes to the underlying writer unless an earlier write failed.
+func (tp *treePrinter) printf(format string, args ...any) {
+	if tp.err != nil {
+		return
+	}
+	_, tp.err = fmt.Fprintf(tp.w, format, args...)
+}
+
+// mark returns the marker suffix for the node of the given kind and ID.
+func (tp *treePrinter) mark(kind string, id int) string {
+	if tp.marked != nil && tp.marked.Kind == kind && tp.marked.ID == id {
+		return tp.glyphs.marker
+	}
+	return ""
+}
+
+// id returns a node's ID in the ID style.
+func (tp *treePrinter) id(id int) string {
+	return tp.paint.paint(styleID, strconv.Itoa(id))
+}
+
+// annotate returns the statistics suffix for group, if enabled.
+func (tp *treePrinter) annotate(group gitlab.Group) string {
+	if !tp.cfg.stats {
+		return ""
+	}
+	st := gitlab.ComputeStats(group)
+	parts := []string{
+		fmt.Sprintf("projects %d/%d", st.DirectProjects, st.TotalProjects),
+		fmt.Sprintf("subgroups %d/%d", st.DirectSubgroups, st.TotalSubgroups),
+	}
+	if !st.LastActivity.IsZero() {
+		parts = append(parts, "active "+st.LastActivity.Format(time.DateOnly))
+	}
+	if st.ArchivedProjects > 0 {
+		parts = append(parts, fmt.Sprintf("archived %d", st.ArchivedProjects))
+	}
+	if st.PrivateProjects > 0 {
+		parts = append(parts, fmt.Sprintf("private %d", st.PrivateProjects))
+	}
+	return "  " + tp.paint.paint(styleAnnotated, "("+strings.Join(parts, ", ")+")")
+}
+
+// summary writes the statistics footer for rootGroup.
+func (tp *treePrinter) summary(rootGroup gitlab.Group) {
+	st := gitlab.ComputeStats(rootGroup)
+	tp.printf("\nSummary for %s:\n", rootGroup.FullPath)
+	tp.printf("  Projects:  %d (%d direct)\n", st.TotalProjects, st.DirectProjects)
+	tp.printf("  Subgroups: %d (%d direct)\n", st.TotalSubgroups, st.DirectSubgroups)
+	tp.printf("  Archived:  %d\n", st.ArchivedProjects)
+	tp.printf("  Private:   %d\n", st.PrivateProjects)
+	if !st.LastActivity.IsZero() {
+		tp.printf("  Last activity: %s\n", st.LastActivity.Format(time.DateOnly))
+	}
+}
+
+// print writes rootGroup and all of its populated descendants.
+func (tp *treePrinter) print(rootGroup gitlab.Group) {
+	tp.printf("\n%s (ID: %s)%s%s\n", tp.paint.name(groupStyle(rootGroup), rootGroup.FullPath), tp.id(rootGroup.ID), tp.annotate(rootGroup), tp.mark("group", rootGroup.ID)) // Print the root group path itself
 
 	totalChildren := len(rootGroup.Subgroups) + len(rootGroup.Projects)
 	childIndex := 0
 
-	// Print subgroups (already sorted by PopulateGroupHierarchy)
+	// Print subgroups, then projects, in the order given
 	for _, subgroup := range rootGroup.Subgroups {
 		childIndex++
-		printHierarchyRecursive(subgroup, "", childIndex == totalChildren) // Start with empty prefix
+		tp.printHierarchyRecursive(subgroup, "", childIndex == totalChildren) // Start with empty prefix
 	}
 
-	// Print projects (already sorted by PopulateGroupHierarchy)
 	for _, project := range rootGroup.Projects {
 		childIndex++
-		printHierarchyRecursive(project, "", childIndex == totalChildren) // Start with empty prefix
+		tp.printHierarchyRecursive(project, "", childIndex == totalChildren) // Start with empty prefix
+	}
+
+	if tp.cfg.stats {
+		tp.summary(rootGroup)
 	}
 }
 
 // printHierarchyRecursive is the internal recursive helper for PrintHierarchy.
-func printHierarchyRecursive(item interface{}, prefix string, isLast bool) {
-	connector := treeBranch
+func (tp *treePrinter) printHierarchyRecursive(item interface{}, prefix string, isLast bool) {
+	g := tp.glyphs
+	connector := g.branch
 	if isLast {
-		connector = treeCorner
+		connector = g.corner
 	}
 
 	switch v := item.(type) {
 	case gitlab.Group:
 		// Print the group node
-		fmt.Printf("%s%s%s%s %s [G] [ID=%d]\n", prefix, connector, treeHorizontal, treeSpace, v.Name, v.ID)
+		style := groupStyle(v)
+		tp.printf("%s%s%s%s %s %s [ID=%s]%s%s\n", prefix, connector, g.horizontal, g.space, tp.paint.name(style, v.Name), tp.paint.paint(style, "[G]"), tp.id(v.ID), tp.annotate(v), tp.mark("group", v.ID))
 
 		// Prepare prefix for children
 		childPrefix := prefix
 		if isLast {
-			childPrefix += treeSpace + treeSpace + treeSpace + treeSpace // 4 spaces
+			childPrefix += g.space + g.space + g.space + g.space // 4 spaces
 		} else {
-			childPrefix += treeVertical + treeSpace + treeSpace + treeSpace // Vertical line + 3 spaces
+			childPrefix += g.vertical + g.space + g.space + g.space // Vertical line + 3 spaces
 		}
 
 		// Print subgroups and projects
@@ -88,15 +318,16 @@ func printHierarchyRecursive(item interface{}, prefix string, isLast bool) {
 
 		for _, subgroup := range v.Subgroups {
 			childIndex++
-			printHierarchyRecursive(subgroup, childPrefix, childIndex == totalChildren)
+			tp.printHierarchyRecursive(subgroup, childPrefix, childIndex == totalChildren)
 		}
 		for _, project := range v.Projects {
 			childIndex++
-			printHierarchyRecursive(project, childPrefix, childIndex == totalChildren)
+			tp.printHierarchyRecursive(project, childPrefix, childIndex == totalChildren)
 		}
 
 	case gitlab.Project:
 		// Print the project node (leaf)
-		fmt.Printf("%s%s%s%s %s [P] [ID=%d]\n", prefix, connector, treeHorizontal, treeSpace, v.Name, v.ID)
+		style := projectStyle(v)
+		tp.printf("%s%s%s%s %s %s [ID=%s]%s\n", prefix, connector, g.horizontal, g.space, tp.paint.name(style, v.Name), tp.paint.paint(style, "[P]"), tp.id(v.ID), tp.mark("project", v.ID))
 	}
 }
@@ -0,0 +1,148 @@
+package display
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/*.golden with the current output")
+
+// checkGolden compares got with testdata/name.golden, rewriting the file
+// instead when the tests run with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run with -update to accept it)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// fixtureTree returns platform with two populated subgroups, an empty
+// subgroup, a chain of groups eight levels deep and a project of its own.
+func fixtureTree() gitlab.Group {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC) }
+	project := func(id int, path, name string) gitlab.Project {
+		return gitlab.Project{ID: id, PathWithNamespace: path + "/" + name, Name: name, Visibility: "internal", LastActivityAt: day(id % 28)}
+	}
+
+	// infra/l1/l2/.../l7, with a project at the bottom
+	deep := gitlab.Group{ID: 27, Name: "l7", FullPath: "platform/infra/l1/l2/l3/l4/l5/l6/l7"}
+	deep.Projects = []gitlab.Project{project(40, deep.FullPath, "bottom")}
+	for id := 26; id >= 21; id-- {
+		parent := gitlab.Group{ID: id, Name: "l" + string(rune('0'+id-20)), FullPath: filepath.Dir(deep.FullPath)}
+		parent.Subgroups = []gitlab.Group{deep}
+		deep = parent
+	}
+	infra := gitlab.Group{ID: 2, Name: "infra", FullPath: "platform/infra", Subgroups: []gitlab.Group{deep}}
+	infra.Projects = []gitlab.Project{project(30, infra.FullPath, "terraform")}
+
+	teams := gitlab.Group{ID: 3, Name: "teams", FullPath: "platform/teams"}
+	archived := project(32, teams.FullPath, "api-v1")
+	archived.Archived = true
+	private := project(33, teams.FullPath, "secrets")
+	private.Visibility = "private"
+	teams.Projects = []gitlab.Project{project(31, teams.FullPath, "web"), archived, private}
+
+	empty := gitlab.Group{ID: 4, Name: "empty", FullPath: "platform/empty"}
+
+	return gitlab.Group{
+		ID:        1,
+		Name:      "platform",
+		FullPath:  "platform",
+		Subgroups: []gitlab.Group{infra, teams, empty},
+		Projects:  []gitlab.Project{project(10, "platform", "handbook")},
+	}
+}
+
+func TestFprintLists(t *testing.T) {
+	groups, projects := gitlab.Flatten(fixtureTree())
+	short := []gitlab.Group{{ID: 1, FullPath: "platform"}, {ID: 3, FullPath: "platform/teams"}}
+	café := []gitlab.Project{{ID: 5, PathWithNamespace: "platform/café"}, {ID: 123456, PathWithNamespace: "platform/teams/web"}}
+
+	tests := []struct {
+		name  string
+		print func(*bytes.Buffer) error
+	}{
+		{"groups", func(b *bytes.Buffer) error { return FprintGroupList(b, groups) }},
+		{"projects", func(b *bytes.Buffer) error { return FprintProjectList(b, projects) }},
+		{"projects_width", func(b *bytes.Buffer) error { return FprintProjectList(b, café, WithWidth(30)) }},
+		// The longest path is a project, then a group: both columns line up
+		{"both", func(b *bytes.Buffer) error { return FprintLists(b, short, projects) }},
+		{"both_groups_longer", func(b *bytes.Buffer) error { return FprintLists(b, groups, café) }},
+		{"both_no_groups", func(b *bytes.Buffer) error { return FprintLists(b, nil, café) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tt.print(&b); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, "list_"+tt.name, b.Bytes())
+		})
+	}
+}
+
+func TestFprintHierarchy(t *testing.T) {
+	root := fixtureTree()
+	tests := []struct {
+		name string
+		root gitlab.Group
+		opts []PrintOption
+	}{
+		{"unicode", root, nil},
+		{"ascii", root, []PrintOption{WithASCII(true)}},
+		{"stats", root, []PrintOption{WithStats(true)}},
+		{"color", root, []PrintOption{WithColor(true), WithHighlight("API")}},
+		{"empty", gitlab.Group{ID: 9, Name: "sandbox", FullPath: "sandbox"}, nil},
+		{"empty_stats", gitlab.Group{ID: 9, Name: "sandbox", FullPath: "sandbox"}, []PrintOption{WithStats(true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := FprintHierarchy(&b, tt.root, tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, "tree_"+tt.name, b.Bytes())
+		})
+	}
+}
+
+func TestFprintAncestors(t *testing.T) {
+	// The chain from platform down to the project at the bottom of infra
+	chain := fixtureTree()
+	chain.Projects = nil
+	chain.Subgroups = chain.Subgroups[:1]
+	infra := &chain.Subgroups[0]
+	infra.Projects = nil
+
+	for _, ascii := range []bool{false, true} {
+		name := "ancestors"
+		if ascii {
+			name += "_ascii"
+		}
+		var b bytes.Buffer
+		if err := FprintAncestors(&b, chain, Record{Kind: "project", ID: 40}, WithASCII(ascii)); err != nil {
+			t.Fatal(err)
+		}
+		checkGolden(t, name, b.Bytes())
+	}
+}
@@ -0,0 +1,88 @@
+package display
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+//go:embed report.html
+var reportHTML string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"lower": strings.ToLower,
+}).Parse(reportHTML))
+
+// Report is the content of an HTML report.
+type Report struct {
+	Host        string         // GitLab host the data came from
+	GeneratedAt time.Time      // When the data was fetched
+	Filters     []string       // Human-readable description of each filter used, e.g. "search: platform"
+	Trees       []gitlab.Group // Populated hierarchies, one per matching root group
+	Groups      []gitlab.Group
+	Projects    []gitlab.Project
+}
+
+// reportRow is one line of the report's searchable table.
+type reportRow struct {
+	Record
+	WebURL string
+}
+
+// rows merges the listed groups and projects with every node of the trees,
+// dropping duplicates, and sorts them by path.
+func (r Report) rows() []reportRow {
+	seen := make(map[string]bool)
+	var rows []reportRow
+	add := func(rec Record, webURL string) {
+		key := rec.Kind + ":" + rec.Path
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		rows = append(rows, reportRow{Record: rec, WebURL: webURL})
+	}
+	var addTree func(g gitlab.Group)
+	addTree = func(g gitlab.Group) {
+		add(GroupRecord(g), g.WebURL)
+		for _, sub := range g.Subgroups {
+			addTree(sub)
+		}
+		for _, p := range g.Projects {
+			add(ProjectRecord(p), p.WebURL)
+		}
+	}
+	for _, g := range r.Groups {
+		add(GroupRecord(g), g.WebURL)
+	}
+	for _, p := range r.Projects {
+		add(ProjectRecord(p), p.WebURL)
+	}
+	for _, tree := range r.Trees {
+		addTree(tree)
+	}
+	slices.SortStableFunc(rows, func(a, b reportRow) int {
+		return gitlab.ComparePaths(a.Path, b.Path)
+	})
+	return rows
+}
+
+// WriteHTMLReport renders r as a self-contained HTML page with a collapsible
+// hierarchy and a searchable table. All styles and scripts are inlined, so
+// the page needs no network access.
+func WriteHTMLReport(w io.Writer, r Report) error {
+	return reportTemplate.Execute(w, struct {
+		Report
+		Generated string
+		Rows      []reportRow
+	}{
+		Report:    r,
+		Generated: r.GeneratedAt.Format(time.RFC1123),
+		Rows:      r.rows(),
+	})
+}
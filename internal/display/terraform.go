@@ -0,0 +1,161 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+// tfUnsafe matches runs of characters not allowed in a Terraform resource name.
+var tfUnsafe = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// TerraformName returns the resource name for the item at fullPath, e.g.
+// "platform/teams/api" becomes "platform_teams_api". Names that would
+// start with a digit get a leading underscore.
+func TerraformName(fullPath string) string {
+	name := strings.Trim(tfUnsafe.ReplaceAllString(strings.ToLower(fullPath), "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// terraformEncoder writes an import block and a minimal resource for each
+// group and project, for bringing them under the gitlab Terraform provider.
+type terraformEncoder struct {
+	w      io.Writer
+	groups map[int]string  // Resource name of each group written so far, by ID
+	names  map[string]bool // Resource names in use, per resource type
+	wrote  map[string]bool // Items written so far, by kind and ID
+}
+
+func newTerraformEncoder(w io.Writer) *terraformEncoder {
+	return &terraformEncoder{
+		w:      w,
+		groups: make(map[int]string),
+		names:  make(map[string]bool),
+		wrote:  make(map[string]bool),
+	}
+}
+
+// name returns a unique name for a resource of the given type at p, appending
+// the ID if two paths sanitize to the same name.
+func (e *terraformEncoder) name(resource, p string, id int) string {
+	name := TerraformName(p)
+	if e.names[resource+"."+name] {
+		name += "_" + strconv.Itoa(id)
+	}
+	e.names[resource+"."+name] = true
+	return name
+}
+
+// parentRef returns a reference to the group's resource if it was written
+// earlier, or its literal ID otherwise.
+func (e *terraformEncoder) parentRef(id int) string {
+	if name, ok := e.groups[id]; ok {
+		return "gitlab_group." + name + ".id"
+	}
+	return strconv.Itoa(id)
+}
+
+// block writes an import block for resource.name followed by the resource
+// with the given attributes, in order.
+func (e *terraformEncoder) block(resource, name string, id int, attrs [][2]string) error {
+	if len(e.wrote) > 0 {
+		if _, err := fmt.Fprintln(e.w); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(e.w, "import {\n  to = %s.%s\n  id = \"%d\"\n}\n\n", resource, name, id); err != nil {
+		return err
+	}
+	width := 0
+	for _, a := range attrs {
+		width = max(width, len(a[0]))
+	}
+	if _, err := fmt.Fprintf(e.w, "resource %q %q {\n", resource, name); err != nil {
+		return err
+	}
+	for _, a := range attrs {
+		if _, err := fmt.Fprintf(e.w, "  %-*s = %s\n", width, a[0], a[1]); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(e.w, "}")
+	return err
+}
+
+func (e *terraformEncoder) Group(g gitlab.Group) error {
+	key := fmt.Sprintf("group:%d", g.ID)
+	if e.wrote[key] {
+		return nil // Already written as part of an earlier tree
+	}
+	name := e.name("gitlab_group", g.FullPath, g.ID)
+	attrs := [][2]string{
+		{"name", strconv.Quote(g.Name)},
+		{"path", strconv.Quote(path.Base(g.FullPath))},
+	}
+	if g.ParentID != nil {
+		attrs = append(attrs, [2]string{"parent_id", e.parentRef(*g.ParentID)})
+	}
+	if err := e.block("gitlab_group", name, g.ID, attrs); err != nil {
+		return err
+	}
+	e.wrote[key] = true
+	e.groups[g.ID] = name
+	return nil
+}
+
+func (e *terraformEncoder) Project(p gitlab.Project) error {
+	key := fmt.Sprintf("project:%d", p.ID)
+	if e.wrote[key] {
+		return nil
+	}
+	name := e.name("gitlab_project", p.PathWithNamespace, p.ID)
+	attrs := [][2]string{
+		{"name", strconv.Quote(p.Name)},
+		{"path", strconv.Quote(path.Base(p.PathWithNamespace))},
+	}
+	if rec := ProjectRecord(p); rec.ParentID != nil {
+		attrs = append(attrs, [2]string{"namespace_id", e.parentRef(*rec.ParentID)})
+	}
+	if err := e.block("gitlab_project", name, p.ID, attrs); err != nil {
+		return err
+	}
+	e.wrote[key] = true
+	return nil
+}
+
+// Tree writes root and its descendants parents-first, so every parent_id
+// and namespace_id below the root refers to a resource in the output.
+func (e *terraformEncoder) Tree(root gitlab.Group) error {
+	if err := e.Group(root); err != nil {
+		return err
+	}
+	for _, sub := range root.Subgroups {
+		if sub.ParentID == nil {
+			sub.ParentID = &root.ID
+		}
+		if err := e.Tree(sub); err != nil {
+			return err
+		}
+	}
+	for _, p := range root.Projects {
+		if p.Namespace.ID == 0 {
+			p.Namespace = gitlab.Namespace{ID: root.ID, Kind: "group", FullPath: root.FullPath}
+		}
+		if err := e.Project(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *terraformEncoder) Close() error {
+	return nil
+}
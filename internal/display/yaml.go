@@ -0,0 +1,148 @@
+package display
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+// yamlPlain matches strings that can be written as plain YAML scalars
+// without quoting.
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_./][A-Za-z0-9 _./-]*$`)
+
+// yamlFloat matches plain scalars such as .5 that YAML would read as numbers.
+var yamlFloat = regexp.MustCompile(`^\.[0-9]`)
+
+// yamlReserved holds plain scalars YAML would read as something other than
+// a string, lower-cased.
+var yamlReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true, "~": true, ".inf": true, ".nan": true,
+}
+
+// yamlString returns s as a YAML scalar, quoting it only when needed.
+func yamlString(s string) string {
+	if yamlPlain.MatchString(s) && !strings.HasSuffix(s, " ") && !yamlReserved[strings.ToLower(s)] && !yamlFloat.MatchString(s) {
+		return s
+	}
+	// A JSON string is also a valid double-quoted YAML scalar
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
+
+// yamlEncoder writes a single YAML sequence of records, nesting subgroups
+// and projects under each group for trees, after the metadata as comments
+// if set. It needs no third-party modules.
+type yamlEncoder struct {
+	w     io.Writer
+	meta  *Metadata
+	count int
+}
+
+// begin counts an item, writing the metadata comments before the first.
+func (e *yamlEncoder) begin() error {
+	e.count++
+	if e.count > 1 || e.meta == nil {
+		return nil
+	}
+	header := fmt.Sprintf("# Host: %s\n# Generated: %s", e.meta.Host, e.meta.GeneratedAt.Format(time.RFC3339))
+	if e.meta.Version != "" {
+		header += " by glids " + e.meta.Version
+	}
+	if len(e.meta.Filters) > 0 {
+		header += "\n# Filters: " + strings.Join(e.meta.Filters, "; ")
+	}
+	_, err := fmt.Fprintln(e.w, header)
+	return err
+}
+
+// record writes rec as the fields of a mapping. The first line follows
+// first (a sequence dash), the rest are indented by indent.
+func (e *yamlEncoder) record(rec Record, first, indent string) error {
+	lines := []string{
+		"kind: " + rec.Kind,
+		"id: " + strconv.Itoa(rec.ID),
+		"path: " + yamlString(rec.Path),
+		"name: " + yamlString(rec.Name),
+	}
+	if rec.ParentID != nil {
+		lines = append(lines, "parent_id: "+strconv.Itoa(*rec.ParentID))
+	}
+	for i, line := range lines {
+		lead := indent
+		if i == 0 {
+			lead = first
+		}
+		if _, err := fmt.Fprintf(e.w, "%s%s\n", lead, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tree writes node and its children as a sequence item at indent.
+func (e *yamlEncoder) tree(node TreeRecord, indent string) error {
+	fields := indent + "  "
+	if err := e.record(node.Record, indent+"- ", fields); err != nil {
+		return err
+	}
+	if len(node.Subgroups) > 0 {
+		if _, err := fmt.Fprintf(e.w, "%ssubgroups:\n", fields); err != nil {
+			return err
+		}
+		for _, sub := range node.Subgroups {
+			if err := e.tree(sub, fields); err != nil {
+				return err
+			}
+		}
+	}
+	if len(node.Projects) > 0 {
+		if _, err := fmt.Fprintf(e.w, "%sprojects:\n", fields); err != nil {
+			return err
+		}
+		for _, p := range node.Projects {
+			if err := e.record(p, fields+"- ", fields+"  "); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *yamlEncoder) Group(g gitlab.Group) error {
+	if err := e.begin(); err != nil {
+		return err
+	}
+	return e.record(GroupRecord(g), "- ", "  ")
+}
+
+func (e *yamlEncoder) Project(p gitlab.Project) error {
+	if err := e.begin(); err != nil {
+		return err
+	}
+	return e.record(ProjectRecord(p), "- ", "  ")
+}
+
+func (e *yamlEncoder) Tree(root gitlab.Group) error {
+	if err := e.begin(); err != nil {
+		return err
+	}
+	return e.tree(NewTreeRecord(root), "")
+}
+
+func (e *yamlEncoder) Close() error {
+	if e.count == 0 {
+		if err := e.begin(); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(e.w, "[]")
+		return err
+	}
+	return nil
+}
@@ -1,23 +1,40 @@
 package gitlab
 
 import (
-	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"log"
 	"net/http"
-	"os"
-	"sort"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
-
-	"golang.org/x/term" // Added for raw terminal input
 )
 
 const largeFetchThreshold = 50 // Threshold for asking confirmation before fetching many items
 
+// ErrCancelled is returned when the user declines a confirmation prompt.
+var ErrCancelled = errors.New("operation cancelled by user")
+
+// APIError is returned when GitLab answers a request with a non-200 status.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// IsNotFound reports whether err is a 404 answer from GitLab.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
 // Client handles communication with the GitLab API.
 type Client struct {
 	baseURL    string
@@ -25,161 +42,95 @@ type Client struct {
 	httpClient *http.Client
 	logger     *log.Logger
 	confirmFn  func(string) bool
-	// Add channel to signal pausing the status animation
-	pauseStatus chan<- bool // Write-only channel
+	observers  []Observer // Notified of progress, see events.go
+	maxRetries int        // Extra attempts for rate-limited or failed requests, see retry.go
+	sleep      func(time.Duration)
 }
 
-// NewClient creates a new GitLab API client.
-// Modify NewClient to accept the pause channel.
-func NewClient(baseURL, token string, logger *log.Logger, pauseCh chan<- bool) *Client { // Added pauseCh parameter
-	if logger == nil {
-		logger = log.New(io.Discard, "", 0)
-	}
-	return &Client{
-		baseURL:     baseURL,
-		token:       token,
-		httpClient:  &http.Client{},
-		logger:      logger,
-		confirmFn:   defaultConfirmFn,
-		pauseStatus: pauseCh, // Store the channel
+// Option configures a Client created by NewClient.
+type Option func(*Client)
+
+// WithLogger sets the logger used for debug output. By default nothing is logged.
+func WithLogger(logger *log.Logger) Option {
+	return func(c *Client) {
+		if logger != nil {
+			c.logger = logger
+		}
 	}
 }
 
-// SetConfirmationFunction allows overriding the default confirmation function.
-func (c *Client) SetConfirmationFunction(fn func(string) bool) {
-	c.confirmFn = fn
+// WithHTTPClient sets the HTTP client used for API requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
 }
 
-// defaultConfirmFn prompts the user for confirmation.
-// It attempts to read a single character (y/n) without requiring Enter if stdin is a terminal.
-// Otherwise, it falls back to reading a line.
-// It now clears the line using ANSI codes if stderr is a terminal.
-func defaultConfirmFn(message string) bool {
-	stderrFd := int(os.Stderr.Fd())
-	isStderrTerminal := term.IsTerminal(stderrFd)
-
-	if isStderrTerminal {
-		// Clear the current line on stderr using ANSI code before printing the prompt
-		fmt.Fprint(os.Stderr, "\r\x1b[K")
-	} else {
-		// If stderr is not a terminal (e.g., redirected to a file),
-		// print a newline before the prompt to avoid messing up the output format.
-		fmt.Fprintln(os.Stderr)
+// WithConfirmFunc sets the function asked before large fetches. It receives a
+// prompt and returns true to proceed. Without one, large fetches proceed.
+func WithConfirmFunc(fn func(string) bool) Option {
+	return func(c *Client) {
+		c.confirmFn = fn
 	}
-	fmt.Fprint(os.Stderr, message+" (y/n): ") // Print prompt to stderr
-
-	stdinFd := int(os.Stdin.Fd())
-	isStdinTerminal := term.IsTerminal(stdinFd)
-
-	// Use raw mode only if both stdin and stderr are terminals.
-	// We need stderr to be a terminal to properly echo the character without messing up lines.
-	if isStdinTerminal && isStderrTerminal {
-		oldState, err := term.MakeRaw(stdinFd)
-		if err != nil {
-			// Fallback to line reading on error, print error message clearly to stderr
-			fmt.Fprintln(os.Stderr, "\nError setting raw mode, please press Enter after y/n:", err)
-			// Use the fallback reader which reads from Stdin
-			return readLineConfirmation()
-		}
-		defer term.Restore(stdinFd, oldState) // Ensure terminal state is restored
-
-		var buf [1]byte
-		n, err := os.Stdin.Read(buf[:]) // Read one byte (character)
-		if err != nil || n == 0 {
-			fmt.Fprintln(os.Stderr, "\nError reading input:", err) // Print error to stderr
-			return false                                           // Default to no on read error
-		}
-
-		// Handle Ctrl+C explicitly in raw mode (ASCII value 3)
-		if buf[0] == 3 {
-			fmt.Fprintln(os.Stderr, "^C\nOperation cancelled by user.") // Echo ^C and message
-			return false                                                // Treat Ctrl+C as cancellation
-		}
+}
 
-		char := strings.ToLower(string(buf[0]))
-		// Echo the character followed by a newline to stderr, so it appears after the prompt.
-		fmt.Fprintln(os.Stderr, string(buf[0]))
+// NewClient creates a new GitLab API client for the given base URL
+// (e.g. https://gitlab.example.com) and personal access token. Failed
+// requests are not retried unless WithRetries is given.
+func NewClient(baseURL, token string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{},
+		logger:     log.New(io.Discard, "", 0),
+		sleep:      time.Sleep,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
 
-		return char == "y"
-	} else {
-		// Fallback for non-terminal input (stdin) or non-terminal output (stderr)
-		// If stderr wasn't a terminal, the prompt is already printed (possibly after a newline).
-		// If stdin wasn't a terminal, we need line reading anyway.
-		return readLineConfirmation() // Reads from Stdin
-	}
+// BaseURL returns the GitLab URL the client talks to, without a trailing slash.
+func (c *Client) BaseURL() string {
+	return c.baseURL
 }
 
-// readLineConfirmation handles the confirmation by reading a full line.
-// This is used as a fallback when raw terminal input is not available.
-func readLineConfirmation() bool {
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
-	if err != nil {
-		// Print error to stderr if possible
-		fmt.Fprintln(os.Stderr, "\nError reading input line:", err)
-		return false // Default to no on read error
-	}
-	response = strings.ToLower(strings.TrimSpace(response))
-	return response == "y" || response == "yes"
+// SetConfirmationFunction allows overriding the confirmation function.
+func (c *Client) SetConfirmationFunction(fn func(string) bool) {
+	c.confirmFn = fn
 }
 
 // confirmLargeFetch checks if the total number of items exceeds the threshold
 // and asks the user for confirmation if it does. It returns true if the operation
 // should proceed (count is below threshold or user confirmed), false otherwise.
-// Now signals pause/resume via the channel.
+// Observers are notified before and after the prompt.
 func (c *Client) confirmLargeFetch(resourceDescription string, totalCount int) bool {
 	if totalCount <= largeFetchThreshold {
 		return true // No confirmation needed
 	}
 
-	// --- Signal Pause ---
-	// Use a separate flag to know if we paused, so we only resume if we paused.
-	didPause := false
-	if c.pauseStatus != nil {
-		c.logger.Printf("Signalling status pause")
-		// Use non-blocking send in case the channel buffer is full or receiver isn't ready
-		// Although unlikely with a buffer of 1 and the current flow.
-		select {
-		case c.pauseStatus <- true:
-			didPause = true
-			// Give a brief moment for the pause signal to be processed in showStatus
-			// This helps ensure the prompt appears after the status line is cleared.
-			time.Sleep(50 * time.Millisecond)
-		default:
-			c.logger.Printf("Warning: Failed to send pause signal (channel full or nil)")
-			// Proceed without pausing if signal fails
-		}
-	}
-
-	// Log message (will appear on a new line after pausing/clearing)
+	// Log message before the prompt is shown
 	c.logger.Printf("Large number of %s detected: %d", resourceDescription, totalCount)
 
-	// Prepare and show prompt
+	// Let observers (e.g. a status display) get out of the way of the prompt
 	prompt := fmt.Sprintf("This operation will fetch %d %s. Continue?", totalCount, resourceDescription)
-	confirmed := c.confirmFn(prompt)
+	c.emit(Event{Kind: ConfirmationNeeded, Resource: resourceDescription, Prompt: prompt})
+
+	confirmed := true
+	if c.confirmFn != nil {
+		confirmed = c.confirmFn(prompt)
+	}
+	c.emit(Event{Kind: ConfirmationAnswered, Resource: resourceDescription, Prompt: prompt, Confirmed: confirmed})
 
 	if confirmed {
 		c.logger.Printf("User confirmed fetching %d %s", totalCount, resourceDescription)
-		// --- Signal Resume ---
-		if didPause && c.pauseStatus != nil { // Only resume if we paused
-			c.logger.Printf("Signalling status resume")
-			// Use non-blocking send
-			select {
-			case c.pauseStatus <- false:
-				// Resume signal sent
-			default:
-				c.logger.Printf("Warning: Failed to send resume signal (channel full or nil)")
-			}
-		}
-		return true // User confirmed
 	} else {
 		c.logger.Printf("User cancelled operation due to large fetch size (%d %s)", totalCount, resourceDescription)
-		// Do NOT resume pause here. The operation is cancelled.
-		// The calling function should handle the cancellation error.
-		// We also don't need to explicitly clear the prompt line here,
-		// as the calling function will either print an error or exit.
-		return false // User cancelled
 	}
+	return confirmed
 }
 
 // extractPaginationInfo extracts pagination information from response headers.
@@ -214,36 +165,73 @@ func extractPaginationInfo(resp *http.Response) *PaginationInfo {
 		}
 	}
 
+	// Try to extract X-Next-Page header (empty on the last page)
+	if nextPageStr := resp.Header.Get("X-Next-Page"); nextPageStr != "" {
+		if nextPage, err := strconv.Atoi(nextPageStr); err == nil {
+			info.NextPage = nextPage
+		}
+	}
+
+	// Try to extract the rel="next" URL from the Link header (keyset pagination)
+	info.NextLink = nextLink(resp.Header.Values("Link"))
+
 	return info
 }
 
+// nextLink returns the URL of the rel="next" entry in RFC 8288 Link header values,
+// or "" if there is none.
+func nextLink(values []string) string {
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			segments := strings.Split(link, ";")
+			target := strings.TrimSpace(segments[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range segments[1:] {
+				param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+				if param == `rel="next"` || param == "rel=next" {
+					return strings.Trim(target, "<>")
+				}
+			}
+		}
+	}
+	return ""
+}
+
 // Helper function for making authenticated GET requests and decoding JSON.
 // Now returns pagination info alongside the error.
 func (c *Client) get(url string, target interface{}) (*PaginationInfo, error) {
-	c.logger.Printf("Making API request to: %s", url)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
+	endpoint := endpointOf(url)
+	var (
+		resp *http.Response
+		body []byte
+		err  error
+	)
+	for attempt := 0; ; attempt++ {
+		resp, body, err = c.do(url, endpoint)
+		wait, retry := c.retryAfter(resp, err, attempt)
+		if !retry {
+			break
+		}
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			c.logger.Printf("Rate limited on %s, waiting %s", endpoint, wait)
+			c.emit(Event{Kind: RateLimited, Endpoint: endpoint, Duration: wait})
+		}
+		c.logger.Printf("Retrying %s (attempt %d of %d) in %s", url, attempt+2, c.maxRetries+1, wait)
+		c.emit(Event{Kind: RequestRetried, Endpoint: endpoint, Attempt: attempt + 1, Duration: wait})
+		c.sleep(wait)
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
-
-	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error making API request: %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
 	// Extract pagination information
 	paginationInfo := extractPaginationInfo(resp)
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return paginationInfo, fmt.Errorf("error reading response body: %v", err)
-	}
-
 	if resp.StatusCode != http.StatusOK {
 		c.logger.Printf("API request failed with status %d: %s", resp.StatusCode, body)
-		return paginationInfo, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, body)
+		return paginationInfo, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	err = json.Unmarshal(body, target)
@@ -254,6 +242,32 @@ func (c *Client) get(url string, target interface{}) (*PaginationInfo, error) {
 	return paginationInfo, nil
 }
 
+// do makes a single GET request and reads the whole response body,
+// emitting RequestCompleted. A transport error leaves resp nil.
+func (c *Client) do(url, endpoint string) (*http.Response, []byte, error) {
+	c.logger.Printf("Making API request to: %s", url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.emit(Event{Kind: RequestCompleted, Endpoint: endpoint, Duration: time.Since(start)})
+		return nil, nil, fmt.Errorf("error making API request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	c.emit(Event{Kind: RequestCompleted, Endpoint: endpoint, StatusCode: resp.StatusCode, Duration: time.Since(start)})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading response body: %v", err)
+	}
+	return resp, body, nil
+}
+
 // CheckResourceCount fetches just the first page to get total count.
 func (c *Client) CheckResourceCount(resourceType string, allItems bool, searchTerm string) (int, error) {
 	var url string
@@ -288,254 +302,343 @@ func (c *Client) CheckResourceCount(resourceType string, allItems bool, searchTe
 }
 
 // GetProjects fetches projects, optionally filtered by search term and activity.
-// Now checks resource count first if using allProjects flag.
+// It collects everything Projects yields; see Projects for streaming.
 func (c *Client) GetProjects(searchTerm string, allProjects bool) ([]Project, error) {
-	// Check total count if we're using allProjects flag
-	if allProjects {
-		totalCount, err := c.CheckResourceCount("projects", allProjects, searchTerm)
-		if err != nil {
-			// Log the warning but proceed cautiously, as we don't know the real count
-			c.logger.Printf("Warning: Could not determine project count: %v. Proceeding without confirmation.", err)
-		} else {
-			// Use the new confirmation function
-			if !c.confirmLargeFetch("projects", totalCount) {
-				// Return a specific error for cancellation
-				return nil, fmt.Errorf("operation cancelled by user")
-			}
-		}
-	}
+	return Collect(c.Projects(searchTerm, allProjects), 0)
+}
 
-	page := 1
-	allProjectsList := []Project{}
+// Projects returns an iterator over projects, optionally filtered by search
+// term and activity, yielding each match as soon as its page is received.
+// Checks the resource count first (and may ask for confirmation) if using the
+// allProjects flag; a declined confirmation yields ErrCancelled.
+//
+// Projects are listed in ID order, oldest first, using keyset pagination,
+// which is the cheapest way to fetch every project. Callers that stop early
+// should use ProjectsBy so the first projects yielded are the ones wanted.
+func (c *Client) Projects(searchTerm string, allProjects bool) iter.Seq2[Project, error] {
+	// /projects supports keyset pagination, which avoids GitLab's cap on deep offsets
+	return c.projects(searchTerm, allProjects, listRequest{keyset: true})
+}
 
-	for {
-		url := fmt.Sprintf("%s/api/v4/projects?per_page=100&order_by=last_activity_at&sort=desc&page=%d", c.baseURL, page)
-		if !allProjects {
-			thirtyDaysAgo := time.Now().AddDate(0, 0, -30).Format(time.RFC3339)
-			url = fmt.Sprintf("%s&last_activity_after=%s", url, thirtyDaysAgo)
-		}
+// ProjectsBy is like Projects but asks GitLab to list projects in key's
+// order, reversed if reverse is set, so stopping after the first few keeps
+// the right ones. It uses offset pagination, which GitLab caps at 50,000
+// projects. GitLab can't order by full path or size, so those keys list the
+// most recently active projects first and leave the final order to
+// SortProjects; with SortSize each project's Statistics are requested too.
+func (c *Client) ProjectsBy(searchTerm string, allProjects bool, key SortKey, reverse bool) iter.Seq2[Project, error] {
+	orderBy, sort := projectOrder(key, reverse)
+	req := listRequest{orderBy: orderBy, sort: sort}
+	if key == SortSize {
+		req.query = url.Values{"statistics": {"true"}}
+	}
+	return c.projects(searchTerm, allProjects, req)
+}
 
-		var projects []Project
-		_, err := c.get(url, &projects)
-		if err != nil {
-			return nil, err // Error already includes context from c.get
+// projects is the iterator behind Projects and ProjectsBy. req sets the
+// paging and order of the /projects listing, and any extra parameters.
+func (c *Client) projects(searchTerm string, allProjects bool, req listRequest) iter.Seq2[Project, error] {
+	return func(yield func(Project, error) bool) {
+		// Check total count if we're using allProjects flag
+		if allProjects {
+			totalCount, err := c.CheckResourceCount("projects", allProjects, searchTerm)
+			if err != nil {
+				// Log the warning but proceed cautiously, as we don't know the real count
+				c.logger.Printf("Warning: Could not determine project count: %v. Proceeding without confirmation.", err)
+			} else if !c.confirmLargeFetch("projects", totalCount) {
+				// Return a specific error for cancellation
+				yield(Project{}, ErrCancelled)
+				return
+			}
 		}
 
-		c.logger.Printf("Received %d projects for page %d", len(projects), page)
-		if len(projects) == 0 {
-			break
+		query := activityFilter(allProjects)
+		for k, v := range req.query {
+			query[k] = v
 		}
-		allProjectsList = append(allProjectsList, projects...)
-		page++
-	}
+		req.path = "/projects"
+		req.query = query
+		req.resource = "projects"
+		projects := paginate[Project](c, req)
 
-	// Filter projects by search term (client-side)
-	if searchTerm != "" {
-		var filteredProjects []Project
+		// Filter projects by search term (client-side)
 		lowerSearchTerm := strings.ToLower(searchTerm)
-		for _, project := range allProjectsList {
-			if strings.Contains(strings.ToLower(project.PathWithNamespace), lowerSearchTerm) {
-				filteredProjects = append(filteredProjects, project)
+		for project, err := range projects {
+			if err != nil {
+				yield(Project{}, err) // Error already includes context from c.get
+				return
+			}
+			if searchTerm != "" && !strings.Contains(strings.ToLower(project.PathWithNamespace), lowerSearchTerm) {
+				continue
+			}
+			if !yield(project, nil) {
+				return
 			}
 		}
-		c.logger.Printf("Filtered down to %d projects matching search term: %s", len(filteredProjects), searchTerm)
-		return filteredProjects, nil
 	}
-
-	return allProjectsList, nil
 }
 
 // GetGroups fetches groups, optionally filtered by search term and activity.
-// Now checks resource count first if using allGroups flag.
+// It collects everything Groups yields; see Groups for streaming.
 func (c *Client) GetGroups(searchTerm string, allGroups bool) ([]Group, error) {
-	apiSearchUsed := searchTerm != ""
-
-	// Check total count if we're using allGroups flag
-	if allGroups {
-		totalCount, err := c.CheckResourceCount("groups", allGroups, searchTerm)
-		if err != nil {
-			// Log the warning but proceed cautiously
-			c.logger.Printf("Warning: Could not determine group count: %v. Proceeding without confirmation.", err)
-		} else {
-			// Use the new confirmation function
-			resourceDesc := "groups"
-			if searchTerm != "" {
-				resourceDesc = fmt.Sprintf("groups matching '%s'", searchTerm) // More specific description
-			}
-			if !c.confirmLargeFetch(resourceDesc, totalCount) {
-				// Return specific error for cancellation
-				return nil, fmt.Errorf("operation cancelled by user")
-			}
-		}
-	}
-
-	page := 1
-	allGroupsList := []Group{}
+	return Collect(c.Groups(searchTerm, allGroups), 0)
+}
 
-	for {
-		url := fmt.Sprintf("%s/api/v4/groups?per_page=100&page=%d&all_available=true", c.baseURL, page)
-		if !allGroups {
-			thirtyDaysAgo := time.Now().AddDate(0, 0, -30).Format(time.RFC3339)
-			url = fmt.Sprintf("%s&last_activity_after=%s", url, thirtyDaysAgo)
-		}
-		if apiSearchUsed {
-			url = fmt.Sprintf("%s&search=%s", url, searchTerm)
-		}
+// Groups returns an iterator over groups, optionally filtered by search term
+// and activity, yielding each match as soon as its page is received.
+// Checks the resource count first (and may ask for confirmation) if using the
+// allGroups flag; a declined confirmation yields ErrCancelled.
+//
+// Groups are listed by name, GitLab's default order for groups.
+func (c *Client) Groups(searchTerm string, allGroups bool) iter.Seq2[Group, error] {
+	return c.groups(searchTerm, allGroups, listRequest{})
+}
 
-		var groups []Group
-		_, err := c.get(url, &groups)
-		if err != nil {
-			return nil, err
-		}
+// GroupsBy is like Groups but asks GitLab to list groups in key's order,
+// reversed if reverse is set. GitLab can only order groups by ID or name, so
+// other keys list them by name (see GroupsInOrder).
+func (c *Client) GroupsBy(searchTerm string, allGroups bool, key SortKey, reverse bool) iter.Seq2[Group, error] {
+	orderBy, sort := groupOrder(key, reverse)
+	return c.groups(searchTerm, allGroups, listRequest{orderBy: orderBy, sort: sort})
+}
 
-		c.logger.Printf("Received %d groups for page %d", len(groups), page)
-		if len(groups) == 0 {
-			break
+// groups is the iterator behind Groups and GroupsBy. req sets the order of
+// the /groups listing.
+func (c *Client) groups(searchTerm string, allGroups bool, req listRequest) iter.Seq2[Group, error] {
+	return func(yield func(Group, error) bool) {
+		apiSearchUsed := searchTerm != ""
+
+		// Check total count if we're using allGroups flag
+		if allGroups {
+			totalCount, err := c.CheckResourceCount("groups", allGroups, searchTerm)
+			if err != nil {
+				// Log the warning but proceed cautiously
+				c.logger.Printf("Warning: Could not determine group count: %v. Proceeding without confirmation.", err)
+			} else {
+				resourceDesc := "groups"
+				if searchTerm != "" {
+					resourceDesc = fmt.Sprintf("groups matching '%s'", searchTerm) // More specific description
+				}
+				if !c.confirmLargeFetch(resourceDesc, totalCount) {
+					// Return specific error for cancellation
+					yield(Group{}, ErrCancelled)
+					return
+				}
+			}
 		}
-		allGroupsList = append(allGroupsList, groups...)
-		page++
-	}
 
-	// Fallback manual filtering if API search was used but returned nothing
-	if apiSearchUsed && len(allGroupsList) == 0 {
-		c.logger.Printf("No groups found with API search for '%s', trying manual filtering", searchTerm)
-		// Fetch all groups (respecting 'allGroups' flag) without the search term
-		// Note: The recursive call here will re-trigger the confirmation check if needed.
-		allGroupsNoSearch, err := c.GetGroups("", allGroups) // Recursive call
-		if err != nil {
-			// Check if the error was cancellation from the recursive call
-			// Propagate the specific cancellation error if it occurred
-			if err.Error() == "operation cancelled by user" {
-				return nil, err
+		query := activityFilter(allGroups)
+		query.Set("all_available", "true")
+		if apiSearchUsed {
+			query.Set("search", searchTerm)
+		}
+		found := 0
+		req.path, req.query, req.resource = "/groups", query, "groups"
+		for group, err := range paginate[Group](c, req) {
+			if err != nil {
+				yield(Group{}, err)
+				return
+			}
+			found++
+			if !yield(group, nil) {
+				return
 			}
-			return nil, fmt.Errorf("error fetching groups for manual filtering: %w", err)
 		}
 
-		var filteredGroups []Group
-		lowerSearchTerm := strings.ToLower(searchTerm)
-		for _, group := range allGroupsNoSearch {
-			if strings.Contains(strings.ToLower(group.FullPath), lowerSearchTerm) {
-				filteredGroups = append(filteredGroups, group)
+		// Fallback manual filtering if API search was used but returned nothing
+		if apiSearchUsed && found == 0 {
+			c.logger.Printf("No groups found with API search for '%s', trying manual filtering", searchTerm)
+			// Fetch all groups (respecting 'allGroups' flag) without the search term
+			// Note: The recursive call here will re-trigger the confirmation check if needed.
+			lowerSearchTerm := strings.ToLower(searchTerm)
+			matched := 0
+			for group, err := range c.groups("", allGroups, req) {
+				if err != nil {
+					// Propagate the specific cancellation error if it occurred
+					if !errors.Is(err, ErrCancelled) {
+						err = fmt.Errorf("error fetching groups for manual filtering: %w", err)
+					}
+					yield(Group{}, err)
+					return
+				}
+				if !strings.Contains(strings.ToLower(group.FullPath), lowerSearchTerm) {
+					continue
+				}
+				matched++
+				if !yield(group, nil) {
+					return
+				}
 			}
+			c.logger.Printf("Manually filtered to %d groups containing '%s'", matched, searchTerm)
 		}
-		c.logger.Printf("Manually filtered to %d groups containing '%s'", len(filteredGroups), searchTerm)
-		return filteredGroups, nil
 	}
+}
 
-	return allGroupsList, nil
+// CountSubgroups returns the number of direct subgroups of a group, read
+// from the X-Total header of a one-item page.
+func (c *Client) CountSubgroups(groupID int, allGroups bool) (int, error) {
+	return c.countListing(fmt.Sprintf("/groups/%d/subgroups", groupID), activityFilter(allGroups))
 }
 
-// getSubgroups fetches direct subgroups for a given group ID.
-// Now also checks resource count first if using allGroups flag.
-func (c *Client) getSubgroups(groupID int, allGroups bool) ([]Group, error) {
-	// First check how many subgroups there are
-	url := fmt.Sprintf("%s/api/v4/groups/%d/subgroups?per_page=1&page=1", c.baseURL, groupID)
-	if !allGroups {
-		thirtyDaysAgo := time.Now().AddDate(0, 0, -30).Format(time.RFC3339)
-		url = fmt.Sprintf("%s&last_activity_after=%s", url, thirtyDaysAgo)
-	}
+// CountGroupProjects returns the number of projects directly in a group, read
+// from the X-Total header of a one-item page.
+func (c *Client) CountGroupProjects(groupID int, allProjects bool) (int, error) {
+	query := activityFilter(allProjects)
+	query.Set("include_subgroups", "false")
+	return c.countListing(fmt.Sprintf("/groups/%d/projects", groupID), query)
+}
 
-	var singleGroup []Group
-	paginationInfo, err := c.get(url, &singleGroup)
+// countListing fetches a one-item page of a listing and returns its X-Total.
+func (c *Client) countListing(path string, query url.Values) (int, error) {
+	query.Set("per_page", "1")
+	query.Set("page", "1")
+	var items []json.RawMessage // Just need something to unmarshal into
+	paginationInfo, err := c.get(c.baseURL+"/api/v4"+path+"?"+query.Encode(), &items)
 	if err != nil {
-		// Log warning, proceed without confirmation
-		c.logger.Printf("Warning: Could not determine subgroup count for group %d: %v. Proceeding without confirmation.", groupID, err)
-	} else if allGroups { // Only ask confirmation if fetching all items
-		// Use the new confirmation function with context
-		resourceDesc := fmt.Sprintf("subgroups for group %d", groupID)
-		if !c.confirmLargeFetch(resourceDesc, paginationInfo.Total) {
-			// Return specific error for cancellation
-			return nil, fmt.Errorf("operation cancelled by user")
-		}
+		return 0, err
 	}
+	return paginationInfo.Total, nil
+}
 
-	page := 1
-	subgroupsList := []Group{}
-
-	for {
-		url := fmt.Sprintf("%s/api/v4/groups/%d/subgroups?per_page=100&page=%d", c.baseURL, groupID, page)
-		if !allGroups {
-			thirtyDaysAgo := time.Now().AddDate(0, 0, -30).Format(time.RFC3339)
-			url = fmt.Sprintf("%s&last_activity_after=%s", url, thirtyDaysAgo)
-		}
+// GetSubgroups fetches direct subgroups for a given group ID.
+// It collects everything Subgroups yields.
+func (c *Client) GetSubgroups(groupID int, allGroups bool) ([]Group, error) {
+	return Collect(c.Subgroups(groupID, allGroups), 0)
+}
 
-		var groups []Group
-		_, err := c.get(url, &groups)
+// Subgroups returns an iterator over the direct subgroups of a group, fetched
+// page by page only as they are consumed. Checks the resource count first
+// (and may ask for confirmation) if using the allGroups flag.
+func (c *Client) Subgroups(groupID int, allGroups bool) iter.Seq2[Group, error] {
+	return func(yield func(Group, error) bool) {
+		// First check how many subgroups there are
+		total, err := c.CountSubgroups(groupID, allGroups)
 		if err != nil {
-			return nil, fmt.Errorf("error fetching subgroups for group %d: %w", groupID, err)
+			// Log warning, proceed without confirmation
+			c.logger.Printf("Warning: Could not determine subgroup count for group %d: %v. Proceeding without confirmation.", groupID, err)
+		} else if allGroups { // Only ask confirmation if fetching all items
+			resourceDesc := fmt.Sprintf("subgroups for group %d", groupID)
+			if !c.confirmLargeFetch(resourceDesc, total) {
+				// Return specific error for cancellation
+				yield(Group{}, ErrCancelled)
+				return
+			}
 		}
 
-		c.logger.Printf("Received %d subgroups for group ID %d, page %d", len(groups), groupID, page)
-		if len(groups) == 0 {
-			break
+		for group, err := range paginate[Group](c, listRequest{
+			path:     fmt.Sprintf("/groups/%d/subgroups", groupID),
+			query:    activityFilter(allGroups),
+			resource: "subgroups",
+			groupID:  groupID,
+		}) {
+			if err != nil {
+				err = fmt.Errorf("error fetching subgroups for group %d: %w", groupID, err)
+			}
+			if !yield(group, err) || err != nil {
+				return
+			}
 		}
-		subgroupsList = append(subgroupsList, groups...)
-		page++
 	}
-	return subgroupsList, nil
 }
 
-// getProjectsForGroup fetches direct projects for a given group ID.
-// Now also checks resource count first if using allProjects flag.
-func (c *Client) getProjectsForGroup(groupID int, allProjects bool) ([]Project, error) {
-	// First check how many projects there are
-	url := fmt.Sprintf("%s/api/v4/groups/%d/projects?per_page=1&page=1&include_subgroups=false", c.baseURL, groupID)
-	if !allProjects {
-		thirtyDaysAgo := time.Now().AddDate(0, 0, -30).Format(time.RFC3339)
-		url = fmt.Sprintf("%s&last_activity_after=%s", url, thirtyDaysAgo)
-	}
-
-	var singleProject []Project
-	paginationInfo, err := c.get(url, &singleProject)
-	if err != nil {
-		// Log warning, proceed without confirmation
-		c.logger.Printf("Warning: Could not determine project count for group %d: %v. Proceeding without confirmation.", groupID, err)
-	} else if allProjects { // Only ask confirmation if fetching all items
-		// Use the new confirmation function with context
-		resourceDesc := fmt.Sprintf("projects for group %d", groupID)
-		if !c.confirmLargeFetch(resourceDesc, paginationInfo.Total) {
-			// Return specific error for cancellation
-			return nil, fmt.Errorf("operation cancelled by user")
-		}
-	}
-
-	page := 1
-	projectsList := []Project{}
+// GetGroupProjects fetches direct projects for a given group ID.
+// It collects everything GroupProjects yields.
+func (c *Client) GetGroupProjects(groupID int, allProjects bool) ([]Project, error) {
+	return Collect(c.GroupProjects(groupID, allProjects), 0)
+}
 
-	for {
-		url := fmt.Sprintf("%s/api/v4/groups/%d/projects?per_page=100&page=%d&include_subgroups=false", c.baseURL, groupID, page)
-		if !allProjects {
-			thirtyDaysAgo := time.Now().AddDate(0, 0, -30).Format(time.RFC3339)
-			url = fmt.Sprintf("%s&last_activity_after=%s", url, thirtyDaysAgo)
-		}
+// GroupProjects returns an iterator over the projects directly in a group,
+// fetched page by page only as they are consumed. Checks the resource count
+// first (and may ask for confirmation) if using the allProjects flag.
+func (c *Client) GroupProjects(groupID int, allProjects bool) iter.Seq2[Project, error] {
+	return c.groupProjects(groupID, allProjects, false)
+}
 
-		var projects []Project
-		_, err := c.get(url, &projects)
+// groupProjects is the iterator behind GroupProjects, requesting each
+// project's Statistics if statistics is set.
+func (c *Client) groupProjects(groupID int, allProjects, statistics bool) iter.Seq2[Project, error] {
+	return func(yield func(Project, error) bool) {
+		// First check how many projects there are
+		total, err := c.CountGroupProjects(groupID, allProjects)
 		if err != nil {
-			return nil, fmt.Errorf("error fetching projects for group %d: %w", groupID, err)
+			// Log warning, proceed without confirmation
+			c.logger.Printf("Warning: Could not determine project count for group %d: %v. Proceeding without confirmation.", groupID, err)
+		} else if allProjects { // Only ask confirmation if fetching all items
+			resourceDesc := fmt.Sprintf("projects for group %d", groupID)
+			if !c.confirmLargeFetch(resourceDesc, total) {
+				// Return specific error for cancellation
+				yield(Project{}, ErrCancelled)
+				return
+			}
 		}
 
-		c.logger.Printf("Received %d projects for group ID %d, page %d", len(projects), groupID, page)
-		if len(projects) == 0 {
-			break
+		query := activityFilter(allProjects)
+		query.Set("include_subgroups", "false")
+		if statistics {
+			query.Set("statistics", "true")
+		}
+		for project, err := range paginate[Project](c, listRequest{
+			path:     fmt.Sprintf("/groups/%d/projects", groupID),
+			query:    query,
+			resource: "projects",
+			groupID:  groupID,
+		}) {
+			if err != nil {
+				err = fmt.Errorf("error fetching projects for group %d: %w", groupID, err)
+			}
+			if !yield(project, err) || err != nil {
+				return
+			}
 		}
-		projectsList = append(projectsList, projects...)
-		page++
 	}
-	return projectsList, nil
+}
+
+// HierarchyOptions controls how much of a hierarchy PopulateHierarchy fetches.
+type HierarchyOptions struct {
+	AllItems   bool // Ignore the 30-day activity filter
+	MaxDepth   int  // Levels below the root to fetch; 0 for no limit
+	GroupsOnly bool // Fetch subgroups but no projects
+
+	// Sort orders each group's subgroups and projects once they are
+	// populated (SortName if empty), reversed if Reverse is set. SortSize
+	// also requests each project's Statistics.
+	Sort    SortKey
+	Reverse bool
 }
 
 // PopulateGroupHierarchy recursively fetches projects and subgroups for a given group.
 // It modifies the passed group pointer and handles cancellation errors.
 func (c *Client) PopulateGroupHierarchy(group *Group, allItems bool) error {
+	return c.PopulateHierarchy(group, HierarchyOptions{AllItems: allItems})
+}
+
+// PopulateHierarchy is PopulateGroupHierarchy with control over depth and
+// whether projects are fetched. Groups at MaxDepth are included as children
+// of their parent but left unpopulated, so no API calls are made for them.
+func (c *Client) PopulateHierarchy(group *Group, opts HierarchyOptions) error {
+	return c.populateGroup(group, opts, 0)
+}
+
+// populateGroup is the recursive worker behind PopulateHierarchy.
+// depth is the nesting level of group below the root being populated.
+func (c *Client) populateGroup(group *Group, opts HierarchyOptions, depth int) error {
+	if opts.MaxDepth > 0 && depth >= opts.MaxDepth {
+		c.logger.Printf("Depth limit %d reached at group: %s (ID: %d)", opts.MaxDepth, group.FullPath, group.ID)
+		return nil
+	}
 	c.logger.Printf("Populating hierarchy for group: %s (ID: %d)", group.FullPath, group.ID)
+	allItems := opts.AllItems
 	var firstError error // Keep track of the first error (especially cancellation)
 
 	// Get projects for the current group
-	projects, err := c.getProjectsForGroup(group.ID, allItems)
+	var projects []Project
+	var err error
+	if !opts.GroupsOnly {
+		projects, err = Collect(c.groupProjects(group.ID, allItems, opts.Sort == SortSize), 0)
+	}
 	if err != nil {
 		// Check for cancellation first
-		if err.Error() == "operation cancelled by user" {
+		if errors.Is(err, ErrCancelled) {
 			return err // Propagate cancellation immediately
 		}
 		// Log other errors but continue, maybe we can still get subgroups
@@ -549,10 +652,10 @@ func (c *Client) PopulateGroupHierarchy(group *Group, allItems bool) error {
 	}
 
 	// Get direct subgroups for the current group
-	subgroups, err := c.getSubgroups(group.ID, allItems)
+	subgroups, err := c.GetSubgroups(group.ID, allItems)
 	if err != nil {
 		// Check for cancellation first
-		if err.Error() == "operation cancelled by user" {
+		if errors.Is(err, ErrCancelled) {
 			return err // Propagate cancellation immediately
 		}
 		// Log other errors but continue, maybe we already got projects
@@ -568,11 +671,11 @@ func (c *Client) PopulateGroupHierarchy(group *Group, allItems bool) error {
 	// Recursively populate each subgroup
 	group.Subgroups = make([]Group, len(subgroups)) // Allocate space
 	for i := range subgroups {
-		currentSubgroup := subgroups[i]                             // Make a copy
-		err := c.PopulateGroupHierarchy(&currentSubgroup, allItems) // Recursive call
+		currentSubgroup := subgroups[i]                         // Make a copy
+		err := c.populateGroup(&currentSubgroup, opts, depth+1) // Recursive call
 		if err != nil {
 			// Check for cancellation first
-			if err.Error() == "operation cancelled by user" {
+			if errors.Is(err, ErrCancelled) {
 				return err // Propagate cancellation immediately
 			}
 			// Log error for this specific subgroup but continue with others
@@ -585,13 +688,15 @@ func (c *Client) PopulateGroupHierarchy(group *Group, allItems bool) error {
 		group.Subgroups[i] = currentSubgroup // Assign the populated subgroup back
 	}
 
-	// Sort children alphabetically (subgroups first, then projects)
-	sort.SliceStable(group.Subgroups, func(i, j int) bool {
-		return strings.ToLower(group.Subgroups[i].Name) < strings.ToLower(group.Subgroups[j].Name)
-	})
-	sort.SliceStable(group.Projects, func(i, j int) bool {
-		return strings.ToLower(group.Projects[i].Name) < strings.ToLower(group.Projects[j].Name)
-	})
+	// Sort children now that their own subgroups and projects are known
+	key := opts.Sort
+	if key == "" {
+		key = SortName
+	}
+	SortGroups(group.Subgroups, key, opts.Reverse)
+	SortProjects(group.Projects, key, opts.Reverse)
+
+	c.emit(Event{Kind: GroupPopulated, GroupID: group.ID, Group: group, Depth: depth})
 
 	return firstError // Return the first error encountered during population (or nil)
 }
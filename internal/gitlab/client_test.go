@@ -0,0 +1,538 @@
+package gitlab_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bboles/glids/internal/gitlab"
+	"github.com/bboles/glids/internal/gitlabtest"
+)
+
+// addProjects adds n projects to group in the fake, with IDs from first.
+// Each is active and created one hour before the previous one, so ID
+// order and newest-first order are opposites.
+func addProjects(fake *gitlabtest.Server, group gitlab.Group, first, n int) {
+	now := time.Now()
+	for i := range n {
+		id := first + i
+		fake.AddProject(gitlab.Project{
+			ID:                id,
+			Name:              fmt.Sprintf("svc%03d", i),
+			PathWithNamespace: fmt.Sprintf("%s/svc%03d", group.FullPath, i),
+			Namespace:         gitlab.Namespace{ID: group.ID, Kind: "group", FullPath: group.FullPath},
+			CreatedAt:         now.Add(-time.Duration(i) * time.Hour),
+			LastActivityAt:    now.Add(-time.Duration(i) * time.Hour),
+		})
+	}
+}
+
+// listings returns the requests the fake received for path (e.g. "/projects"),
+// ignoring per-item lookups and the one-item count checks.
+func listings(fake *gitlabtest.Server, path string) []string {
+	var reqs []string
+	for _, r := range fake.Requests() {
+		u, err := url.Parse(r)
+		if err == nil && u.Path == "/api/v4"+path && u.Query().Get("per_page") != "1" {
+			reqs = append(reqs, r)
+		}
+	}
+	return reqs
+}
+
+func projectIDs(projects []gitlab.Project) []int {
+	ids := make([]int, len(projects))
+	for i, p := range projects {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+func TestProjectsKeysetPaging(t *testing.T) {
+	for _, n := range []int{0, 1, 99, 100, 101, 250, 300} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			fake := gitlabtest.NewServer()
+			defer fake.Close()
+			platform := gitlab.Group{ID: 1, Name: "platform", FullPath: "platform"}
+			fake.AddGroup(platform)
+			addProjects(fake, platform, 1000, n)
+
+			projects, err := fake.Client().GetProjects("", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(projects) != n {
+				t.Fatalf("got %d projects, want %d", len(projects), n)
+			}
+			ids := projectIDs(projects)
+			if !slices.IsSorted(ids) {
+				t.Errorf("projects not in ID order: %v", ids)
+			}
+
+			// One request per page of 100, and none for an empty page after the last
+			reqs := listings(fake, "/projects")
+			if want := max((n+99)/100, 1); len(reqs) != want {
+				t.Errorf("made %d listing requests, want %d: %v", len(reqs), want, reqs)
+			}
+			for i, r := range reqs {
+				if !strings.Contains(r, "pagination=keyset") {
+					t.Errorf("request %d is not keyset paged: %s", i, r)
+				}
+				if (i > 0) != strings.Contains(r, "id_after=") {
+					t.Errorf("request %d: unexpected id_after use: %s", i, r)
+				}
+			}
+		})
+	}
+}
+
+func TestGroupProjectsOffsetPaging(t *testing.T) {
+	for _, n := range []int{0, 100, 150, 200} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			fake := gitlabtest.NewServer()
+			defer fake.Close()
+			platform := gitlab.Group{ID: 1, Name: "platform", FullPath: "platform"}
+			fake.AddGroup(platform)
+			addProjects(fake, platform, 1000, n)
+
+			projects, err := fake.Client().GetGroupProjects(platform.ID, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(projects) != n {
+				t.Fatalf("got %d projects, want %d", len(projects), n)
+			}
+			seen := make(map[int]bool)
+			for _, p := range projects {
+				if seen[p.ID] {
+					t.Fatalf("project %d listed twice", p.ID)
+				}
+				seen[p.ID] = true
+			}
+
+			reqs := listings(fake, "/groups/1/projects")
+			if want := max((n+99)/100, 1); len(reqs) != want {
+				t.Errorf("made %d listing requests, want %d: %v", len(reqs), want, reqs)
+			}
+			for i, r := range reqs {
+				if want := fmt.Sprintf("page=%d&", i+1); !strings.Contains(r, want) {
+					t.Errorf("request %d lacks %s: %s", i, want, r)
+				}
+			}
+		})
+	}
+}
+
+func TestGroupsOffsetPaging(t *testing.T) {
+	fake := gitlabtest.NewServer()
+	defer fake.Close()
+	for id := 1; id <= 230; id++ {
+		fake.AddGroup(gitlab.Group{ID: id, Name: fmt.Sprintf("g%03d", id), FullPath: fmt.Sprintf("g%03d", id)})
+	}
+
+	groups, err := fake.Client().GetGroups("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 230 {
+		t.Fatalf("got %d groups, want 230", len(groups))
+	}
+	// GitLab lists groups by name by default
+	if groups[0].Name != "g001" || groups[229].Name != "g230" {
+		t.Errorf("groups run from %s to %s, want g001 to g230", groups[0].Name, groups[229].Name)
+	}
+	if reqs := listings(fake, "/groups"); len(reqs) != 3 {
+		t.Errorf("made %d listing requests, want 3: %v", len(reqs), reqs)
+	}
+}
+
+func TestPaginateStopsEarly(t *testing.T) {
+	fake := gitlabtest.NewServer()
+	defer fake.Close()
+	platform := gitlab.Group{ID: 1, Name: "platform", FullPath: "platform"}
+	fake.AddGroup(platform)
+	addProjects(fake, platform, 1000, 250)
+
+	projects, err := gitlab.Collect(fake.Client().Projects("", false), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 5 {
+		t.Errorf("got %d projects, want 5", len(projects))
+	}
+	if reqs := listings(fake, "/projects"); len(reqs) != 1 {
+		t.Errorf("made %d listing requests, want 1: %v", len(reqs), reqs)
+	}
+}
+
+func TestProjectsBy(t *testing.T) {
+	fake := gitlabtest.NewServer()
+	defer fake.Close()
+	platform := gitlab.Group{ID: 1, Name: "platform", FullPath: "platform"}
+	fake.AddGroup(platform)
+	addProjects(fake, platform, 1000, 150) // 1000 is the newest, 1149 the oldest
+
+	tests := []struct {
+		key     gitlab.SortKey
+		reverse bool
+		want    []int
+	}{
+		{gitlab.SortActivity, false, []int{1000, 1001, 1002}},
+		{gitlab.SortActivity, true, []int{1149, 1148, 1147}},
+		{gitlab.SortCreated, false, []int{1000, 1001, 1002}},
+		{gitlab.SortID, true, []int{1149, 1148, 1147}},
+		{gitlab.SortName, false, []int{1000, 1001, 1002}},
+		{gitlab.SortPath, false, []int{1000, 1001, 1002}}, // Most recently active
+		{gitlab.SortPath, true, []int{1000, 1001, 1002}},  // Still most recently active
+	}
+	for _, tt := range tests {
+		projects, err := gitlab.Collect(fake.Client().ProjectsBy("", false, tt.key, tt.reverse), 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := projectIDs(projects); !slices.Equal(got, tt.want) {
+			t.Errorf("ProjectsBy(%s, reverse=%v) = %v, want %v", tt.key, tt.reverse, got, tt.want)
+		}
+	}
+}
+
+func TestGroupsBy(t *testing.T) {
+	fake := gitlabtest.NewServer()
+	defer fake.Close()
+	fake.AddGroup(
+		gitlab.Group{ID: 1, Name: "zeta", FullPath: "zeta"},
+		gitlab.Group{ID: 2, Name: "alpha", FullPath: "alpha"},
+		gitlab.Group{ID: 3, Name: "mu", FullPath: "mu"},
+	)
+	client := fake.Client()
+
+	tests := []struct {
+		key     gitlab.SortKey
+		reverse bool
+		want    []int
+	}{
+		{gitlab.SortID, false, []int{1, 2, 3}},
+		{gitlab.SortID, true, []int{3, 2, 1}},
+		{gitlab.SortPath, true, []int{2, 3, 1}}, // By name, whatever reverse is
+	}
+	for _, tt := range tests {
+		groups, err := gitlab.Collect(client.GroupsBy("", true, tt.key, tt.reverse), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, g := range groups {
+			got = append(got, g.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("GroupsBy(%s, reverse=%v) = %v, want %v", tt.key, tt.reverse, got, tt.want)
+		}
+	}
+}
+
+func TestProjectStatistics(t *testing.T) {
+	fake := gitlabtest.NewServer()
+	defer fake.Close()
+	platform := 1
+	fake.AddGroup(gitlab.Group{ID: platform, Name: "platform", FullPath: "platform"})
+	ns := gitlab.Namespace{ID: platform, Kind: "group"}
+	for id, size := range map[int]int64{10: 500, 11: 9000, 12: 70} {
+		fake.AddProject(gitlab.Project{ID: id, Name: fmt.Sprint(id), PathWithNamespace: fmt.Sprint("platform/", id), Namespace: ns,
+			Statistics: &gitlab.ProjectStatistics{StorageSize: size}})
+	}
+	client := fake.Client()
+
+	plain, err := client.GetProjects("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range plain {
+		if p.Statistics != nil {
+			t.Errorf("Projects requested statistics for %d", p.ID)
+		}
+	}
+
+	bySize, err := gitlab.Collect(client.ProjectsBy("", true, gitlab.SortSize, false), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitlab.SortProjects(bySize, gitlab.SortSize, false)
+	if got, want := projectIDs(bySize), []int{11, 10, 12}; !slices.Equal(got, want) {
+		t.Errorf("projects by size = %v, want %v", got, want)
+	}
+
+	root, err := client.GetGroup("platform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.PopulateHierarchy(&root, gitlab.HierarchyOptions{AllItems: true, Sort: gitlab.SortSize, Reverse: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := projectIDs(root.Projects), []int{12, 10, 11}; !slices.Equal(got, want) {
+		t.Errorf("populated projects by size, reversed = %v, want %v", got, want)
+	}
+}
+
+func TestLastActivityAfter(t *testing.T) {
+	fake := gitlabtest.NewServer()
+	defer fake.Close()
+	platform := gitlab.Group{ID: 1, Name: "platform", FullPath: "platform"}
+	fake.AddGroup(platform)
+	ns := gitlab.Namespace{ID: 1, Kind: "group"}
+	fake.AddProject(
+		gitlab.Project{ID: 10, Name: "new", PathWithNamespace: "platform/new", Namespace: ns, LastActivityAt: time.Now().AddDate(0, 0, -1)},
+		gitlab.Project{ID: 11, Name: "recent", PathWithNamespace: "platform/recent", Namespace: ns, LastActivityAt: time.Now().AddDate(0, 0, -29)},
+		gitlab.Project{ID: 12, Name: "stale", PathWithNamespace: "platform/stale", Namespace: ns, LastActivityAt: time.Now().AddDate(0, 0, -31)},
+	)
+	client := fake.Client()
+
+	tests := []struct {
+		name string
+		list func(all bool) ([]gitlab.Project, error)
+	}{
+		{"GetProjects", func(all bool) ([]gitlab.Project, error) { return client.GetProjects("", all) }},
+		{"GetGroupProjects", func(all bool) ([]gitlab.Project, error) { return client.GetGroupProjects(1, all) }},
+	}
+	for _, tt := range tests {
+		active, err := tt.list(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all, err := tt.list(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, gotAll := projectIDs(active), projectIDs(all)
+		slices.Sort(got)
+		slices.Sort(gotAll)
+		if !slices.Equal(got, []int{10, 11}) {
+			t.Errorf("%s without --all = %v, want [10 11]", tt.name, got)
+		}
+		if !slices.Equal(gotAll, []int{10, 11, 12}) {
+			t.Errorf("%s with --all = %v, want [10 11 12]", tt.name, gotAll)
+		}
+	}
+	if r := listings(fake, "/projects")[0]; !strings.Contains(r, "last_activity_after=") {
+		t.Errorf("listing of active projects lacks last_activity_after: %s", r)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	tests := []struct {
+		status       int
+		wantNotFound bool
+	}{
+		{http.StatusUnauthorized, false},
+		{http.StatusForbidden, false},
+		{http.StatusNotFound, true},
+		{http.StatusInternalServerError, false},
+		{http.StatusServiceUnavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.status), func(t *testing.T) {
+			fake := gitlabtest.NewServer()
+			defer fake.Close()
+			fake.AddGroup(gitlab.Group{ID: 1, Name: "platform", FullPath: "platform"})
+			fake.Fail("/groups", tt.status, 1)
+
+			_, err := fake.Client().GetGroup("platform")
+			var apiErr *gitlab.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("GetGroup = %v, want an APIError", err)
+			}
+			if apiErr.StatusCode != tt.status {
+				t.Errorf("status %d, want %d", apiErr.StatusCode, tt.status)
+			}
+			if gitlab.IsNotFound(err) != tt.wantNotFound {
+				t.Errorf("IsNotFound = %v, want %v", !tt.wantNotFound, tt.wantNotFound)
+			}
+			// The fault was used up
+			if _, err := fake.Client().GetGroup("platform"); err != nil {
+				t.Errorf("second GetGroup = %v", err)
+			}
+		})
+	}
+}
+
+func TestErrorEndsListing(t *testing.T) {
+	fake := gitlabtest.NewServer()
+	defer fake.Close()
+	platform := gitlab.Group{ID: 1, Name: "platform", FullPath: "platform"}
+	fake.AddGroup(platform)
+	addProjects(fake, platform, 1000, 250)
+	fake.Fail("/groups/1/projects", http.StatusBadGateway, -1)
+
+	var yielded int
+	var lastErr error
+	for _, err := range fake.Client().GroupProjects(1, true) {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		yielded++
+	}
+	if yielded != 0 || lastErr == nil {
+		t.Errorf("yielded %d projects and error %v, want none and an error", yielded, lastErr)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	fake := gitlabtest.NewServer()
+	defer fake.Close()
+	fake.AddGroup(gitlab.Group{ID: 1, Name: "platform", FullPath: "platform"})
+	fake.FailWithHeader("/groups/platform", http.StatusTooManyRequests, 2, http.Header{"Retry-After": {"0"}})
+
+	var retried, limited int
+	client := fake.Client(gitlab.WithRetries(2), gitlab.WithObserver(gitlab.ObserverFunc(func(e gitlab.Event) {
+		switch e.Kind {
+		case gitlab.RequestRetried:
+			retried++
+		case gitlab.RateLimited:
+			limited++
+		}
+	})))
+	g, err := client.GetGroup("platform")
+	if err != nil {
+		t.Fatalf("GetGroup with two rate limits and two retries = %v", err)
+	}
+	if g.ID != 1 || retried != 2 || limited != 2 {
+		t.Errorf("got group %d after %d retries and %d rate limits, want group 1 after 2 and 2", g.ID, retried, limited)
+	}
+
+	// Without retries the first failure is returned
+	fake.FailWithHeader("/groups/platform", http.StatusTooManyRequests, 1, http.Header{"Retry-After": {"0"}})
+	if _, err := fake.Client().GetGroup("platform"); err == nil {
+		t.Error("GetGroup with retries off succeeded despite a rate limit")
+	}
+}
+
+// hierarchyFake returns a fake holding:
+//
+//	platform (1)
+//	├── api (10)
+//	├── alpha (2)
+//	│   └── one (20)
+//	└── beta (3)
+//	    ├── two (30)
+//	    └── gamma (4)
+//	        └── three (40)
+func hierarchyFake() *gitlabtest.Server {
+	fake := gitlabtest.NewServer()
+	platform, alpha, beta := 1, 2, 3
+	fake.AddGroup(
+		gitlab.Group{ID: platform, Name: "platform", FullPath: "platform"},
+		gitlab.Group{ID: beta, ParentID: &platform, Name: "beta", FullPath: "platform/beta"},
+		gitlab.Group{ID: alpha, ParentID: &platform, Name: "alpha", FullPath: "platform/alpha"},
+		gitlab.Group{ID: 4, ParentID: &beta, Name: "gamma", FullPath: "platform/beta/gamma"},
+	)
+	for _, p := range []struct {
+		id, group int
+		path      string
+	}{{10, 1, "platform/api"}, {20, 2, "platform/alpha/one"}, {30, 3, "platform/beta/two"}, {40, 4, "platform/beta/gamma/three"}} {
+		name := p.path[strings.LastIndex(p.path, "/")+1:]
+		fake.AddProject(gitlab.Project{ID: p.id, Name: name, PathWithNamespace: p.path, Namespace: gitlab.Namespace{ID: p.group, Kind: "group"}})
+	}
+	return fake
+}
+
+// shape describes a populated hierarchy as "path[children...]" for comparison.
+func shape(g gitlab.Group) string {
+	var parts []string
+	for _, sub := range g.Subgroups {
+		parts = append(parts, shape(sub))
+	}
+	for _, p := range g.Projects {
+		parts = append(parts, p.Name)
+	}
+	return g.Name + "[" + strings.Join(parts, " ") + "]"
+}
+
+func TestPopulateHierarchy(t *testing.T) {
+	fake := hierarchyFake()
+	defer fake.Close()
+	client := fake.Client()
+
+	tests := []struct {
+		opts gitlab.HierarchyOptions
+		want string
+	}{
+		{gitlab.HierarchyOptions{AllItems: true}, "platform[alpha[one] beta[gamma[three] two] api]"},
+		{gitlab.HierarchyOptions{AllItems: true, MaxDepth: 1}, "platform[alpha[] beta[] api]"},
+		{gitlab.HierarchyOptions{AllItems: true, GroupsOnly: true}, "platform[alpha[] beta[gamma[]]]"},
+		{gitlab.HierarchyOptions{AllItems: true, Sort: gitlab.SortID, Reverse: true}, "platform[beta[gamma[three] two] alpha[one] api]"},
+		// Sizes are counted from the populated children: beta has two projects beneath it
+		{gitlab.HierarchyOptions{AllItems: true, Sort: gitlab.SortSize}, "platform[beta[gamma[three] two] alpha[one] api]"},
+	}
+	for _, tt := range tests {
+		root, err := client.GetGroup("platform")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := client.PopulateHierarchy(&root, tt.opts); err != nil {
+			t.Fatal(err)
+		}
+		if got := shape(root); got != tt.want {
+			t.Errorf("PopulateHierarchy(%+v) = %s, want %s", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestPopulateHierarchyPartialError(t *testing.T) {
+	tests := []struct {
+		fail    string // Path prefix that always fails
+		want    string
+		wantErr string
+	}{
+		// The projects of beta are missing, but its subgroups are still populated
+		{"/groups/3/projects", "platform[alpha[one] beta[gamma[three]] api]", "failed getting projects for group 3"},
+		// Without beta's subgroups gamma is unknown; alpha is still populated
+		{"/groups/3/subgroups", "platform[alpha[one] beta[two] api]", "failed getting subgroups for group 3"},
+		// A failing leaf leaves its siblings intact
+		{"/groups/4/", "platform[alpha[one] beta[gamma[] two] api]", "failed to populate subgroup beta"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fail, func(t *testing.T) {
+			fake := hierarchyFake()
+			defer fake.Close()
+			fake.Fail(tt.fail, http.StatusInternalServerError, -1)
+			client := fake.Client()
+
+			root, err := client.GetGroup("platform")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = client.PopulateHierarchy(&root, gitlab.HierarchyOptions{AllItems: true})
+			var apiErr *gitlab.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+				t.Errorf("PopulateHierarchy = %v, want a wrapped 500", err)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %q does not mention %q", err, tt.wantErr)
+			}
+			if got := shape(root); got != tt.want {
+				t.Errorf("populated %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPopulateHierarchyCancelled(t *testing.T) {
+	fake := hierarchyFake()
+	defer fake.Close()
+	alpha := gitlab.Group{ID: 2, FullPath: "platform/alpha"}
+	addProjects(fake, alpha, 1000, 60) // Enough to need confirmation
+
+	client := fake.Client(gitlab.WithConfirmFunc(func(string) bool { return false }))
+	root, err := client.GetGroup("platform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.PopulateHierarchy(&root, gitlab.HierarchyOptions{AllItems: true}); !errors.Is(err, gitlab.ErrCancelled) {
+		t.Errorf("PopulateHierarchy = %v, want ErrCancelled", err)
+	}
+}
@@ -0,0 +1,112 @@
+package gitlab
+
+import "time"
+
+// EventKind identifies what happened in an Event.
+type EventKind int
+
+const (
+	// FetchStarted is emitted before the first page of a listing is requested.
+	FetchStarted EventKind = iota
+	// PageReceived is emitted after each page of a listing has been decoded.
+	PageReceived
+	// ConfirmationNeeded is emitted just before the confirmation function is asked.
+	ConfirmationNeeded
+	// ConfirmationAnswered is emitted once the confirmation function has returned.
+	ConfirmationAnswered
+	// GroupPopulated is emitted when PopulateGroupHierarchy has finished a group.
+	GroupPopulated
+	// RequestCompleted is emitted after every HTTP request to GitLab, including
+	// retried ones. StatusCode is zero if no response was received.
+	RequestCompleted
+	// RequestRetried is emitted before a failed request is retried.
+	RequestRetried
+	// RateLimited is emitted when GitLab answers 429 and the client waits.
+	RateLimited
+)
+
+// String returns a short lower-case name for the kind, suitable for logs.
+func (k EventKind) String() string {
+	switch k {
+	case FetchStarted:
+		return "fetch_started"
+	case PageReceived:
+		return "page_received"
+	case ConfirmationNeeded:
+		return "confirmation_needed"
+	case ConfirmationAnswered:
+		return "confirmation_answered"
+	case GroupPopulated:
+		return "group_populated"
+	case RequestCompleted:
+		return "request_completed"
+	case RequestRetried:
+		return "request_retried"
+	case RateLimited:
+		return "rate_limited"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes progress made by a Client. Only the fields relevant to
+// Kind are set.
+type Event struct {
+	Kind EventKind
+	// Resource describes what is being fetched, e.g. "projects" or "subgroups".
+	Resource string
+	// GroupID is the group a per-group listing or population belongs to.
+	GroupID int
+	// Page holds the pagination headers of the page just received.
+	Page PaginationInfo
+	// Items is the number of items on the page just received.
+	Items int
+	// Prompt is the question passed to the confirmation function.
+	Prompt string
+	// Confirmed is the answer given to Prompt.
+	Confirmed bool
+	// Group is the group that has just been populated.
+	Group *Group
+	// Depth is the nesting level of Group below the root being populated.
+	Depth int
+	// Endpoint is the API path of a request, with IDs replaced by ":id".
+	Endpoint string
+	// StatusCode is the HTTP status of a completed request.
+	StatusCode int
+	// Duration is how long a request took, or how long the client waits
+	// before a retry.
+	Duration time.Duration
+	// Attempt is the number of the retry about to be made, from 1.
+	Attempt int
+}
+
+// Observer receives events from a Client as they happen. Events are delivered
+// synchronously on the goroutine making the API calls.
+type Observer interface {
+	HandleEvent(Event)
+}
+
+// ObserverFunc adapts an ordinary function to the Observer interface.
+type ObserverFunc func(Event)
+
+// HandleEvent calls f(e).
+func (f ObserverFunc) HandleEvent(e Event) {
+	f(e)
+}
+
+// WithObserver registers an observer for client events. It may be given
+// more than once to subscribe several observers.
+func WithObserver(o Observer) Option {
+	return func(c *Client) {
+		if o != nil {
+			c.observers = append(c.observers, o)
+		}
+	}
+}
+
+// emit delivers an event to every registered observer.
+func (c *Client) emit(e Event) {
+	for _, o := range c.observers {
+		o.HandleEvent(e)
+	}
+}
@@ -0,0 +1,49 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// GetProject fetches a single project by numeric ID or full path
+// (e.g. "platform/teams/api").
+func (c *Client) GetProject(idOrPath string) (Project, error) {
+	var project Project
+	_, err := c.get(fmt.Sprintf("%s/api/v4/projects/%s", c.baseURL, url.PathEscape(idOrPath)), &project)
+	if err != nil {
+		return Project{}, fmt.Errorf("error fetching project %s: %w", idOrPath, err)
+	}
+	return project, nil
+}
+
+// GetGroup fetches a single group by numeric ID or full path
+// (e.g. "platform/teams"), without its projects.
+func (c *Client) GetGroup(idOrPath string) (Group, error) {
+	var group Group
+	_, err := c.get(fmt.Sprintf("%s/api/v4/groups/%s?with_projects=false", c.baseURL, url.PathEscape(idOrPath)), &group)
+	if err != nil {
+		return Group{}, fmt.Errorf("error fetching group %s: %w", idOrPath, err)
+	}
+	return group, nil
+}
+
+// GetAncestors walks parent_id upward from group and returns the chain of
+// groups from the top-level group down to group itself.
+func (c *Client) GetAncestors(group Group) ([]Group, error) {
+	chain := []Group{group}
+	seen := map[int]bool{group.ID: true}
+	for current := group; current.ParentID != nil; {
+		if seen[*current.ParentID] {
+			return nil, fmt.Errorf("cycle in group parents at group %d", *current.ParentID)
+		}
+		parent, err := c.GetGroup(fmt.Sprint(*current.ParentID))
+		if err != nil {
+			return nil, err
+		}
+		c.logger.Printf("Group %s (ID: %d) has parent %s (ID: %d)", current.FullPath, current.ID, parent.FullPath, parent.ID)
+		seen[parent.ID] = true
+		chain = append([]Group{parent}, chain...)
+		current = parent
+	}
+	return chain, nil
+}
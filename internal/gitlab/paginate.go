@@ -0,0 +1,167 @@
+package gitlab
+
+import (
+	"iter"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const perPage = 100 // Page size requested from every listing endpoint
+
+// listRequest describes one paginated listing endpoint.
+type listRequest struct {
+	path     string     // API path below /api/v4, e.g. "/groups/12/subgroups"
+	query    url.Values // Filters; paging parameters are added by the paginator
+	keyset   bool       // Use keyset pagination; the endpoint must support order_by=id
+	orderBy  string     // order_by for offset pagination; empty for the endpoint's default
+	sort     string     // sort ("asc" or "desc") for offset pagination
+	resource string     // Reported in events, e.g. "projects"
+	groupID  int        // Reported in events for per-group listings
+}
+
+// firstURL returns the URL of the first page of the listing.
+func (r listRequest) firstURL(baseURL string) string {
+	q := url.Values{}
+	for k, v := range r.query {
+		q[k] = v
+	}
+	q.Set("per_page", strconv.Itoa(perPage))
+	if r.keyset {
+		// Keyset pagination is only available ordered by id; callers sort client-side anyway.
+		q.Set("pagination", "keyset")
+		q.Set("order_by", "id")
+		q.Set("sort", "asc")
+	} else {
+		if r.orderBy != "" {
+			q.Set("order_by", r.orderBy)
+			q.Set("sort", r.sort)
+		}
+		q.Set("page", "1")
+	}
+	return baseURL + "/api/v4" + r.path + "?" + q.Encode()
+}
+
+// paginator walks the pages of a listing. It follows Link rel="next" (keyset)
+// and X-Next-Page (offset) headers and stops when neither is present, so no
+// empty trailing page is fetched. Servers that send no pagination headers at
+// all are paged by number until an empty page is returned.
+type paginator struct {
+	next string
+	page int
+}
+
+// newPaginator starts a paginator at the first page of req.
+func newPaginator(baseURL string, req listRequest) *paginator {
+	return &paginator{next: req.firstURL(baseURL), page: 1}
+}
+
+// done reports whether every page has been fetched.
+func (p *paginator) done() bool {
+	return p.next == ""
+}
+
+// advance moves to the page after the one described by info, which held n items.
+func (p *paginator) advance(info *PaginationInfo, n int) {
+	current := p.next
+	p.next = ""
+	switch {
+	case info.NextLink != "":
+		p.next = info.NextLink
+	case info.NextPage > 0:
+		p.next = withPage(current, info.NextPage)
+	case info.CurrentPage > 0 || info.TotalPages > 0:
+		// Offset headers present but no next page: this was the last one
+	case n > 0 && !isKeyset(current):
+		// No pagination headers at all: fall back to probing the next page
+		p.next = withPage(current, p.page+1)
+	}
+	p.page++
+}
+
+// withPage returns rawURL with its page parameter set to page.
+func withPage(rawURL string, page int) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	q := u.Query()
+	q.Set("page", strconv.Itoa(page))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// isKeyset reports whether rawURL requests keyset pagination.
+func isKeyset(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Query().Get("pagination") == "keyset"
+}
+
+// Paginate returns an iterator over every item of a GitLab listing endpoint,
+// fetching one page at a time as the caller consumes items. path is relative
+// to /api/v4 (e.g. "/groups/12/projects") and query holds any filters; set
+// pagination=keyset in query for endpoints that support keyset pagination.
+// Stopping the iteration early stops fetching. A failed request ends the
+// iteration with a non-nil error.
+func Paginate[T any](c *Client, path string, query url.Values) iter.Seq2[T, error] {
+	return paginate[T](c, listRequest{
+		path:     path,
+		query:    query,
+		keyset:   query.Get("pagination") == "keyset",
+		resource: path,
+	})
+}
+
+// paginate is the iterator behind Paginate and the typed listings.
+// Observers are told when the listing starts and as each page arrives.
+func paginate[T any](c *Client, req listRequest) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		c.emit(Event{Kind: FetchStarted, Resource: req.resource, GroupID: req.groupID})
+
+		p := newPaginator(c.baseURL, req)
+		for !p.done() {
+			var items []T
+			info, err := c.get(p.next, &items)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			c.logger.Printf("Received %d %s (group ID %d), page %d", len(items), req.resource, req.groupID, p.page)
+			c.emit(Event{Kind: PageReceived, Resource: req.resource, GroupID: req.groupID, Page: *info, Items: len(items)})
+
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			p.advance(info, len(items))
+		}
+	}
+}
+
+// Collect drains seq into a slice, stopping after limit items when limit is
+// positive. It returns the first error yielded.
+func Collect[T any](seq iter.Seq2[T, error], limit int) ([]T, error) {
+	all := []T{}
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, item)
+		if limit > 0 && len(all) >= limit {
+			break
+		}
+	}
+	return all, nil
+}
+
+// activityFilter returns query parameters restricting a listing to items
+// active in the last 30 days, or no parameters when all items are wanted.
+func activityFilter(allItems bool) url.Values {
+	q := url.Values{}
+	if !allItems {
+		q.Set("last_activity_after", time.Now().AddDate(0, 0, -30).Format(time.RFC3339))
+	}
+	return q
+}
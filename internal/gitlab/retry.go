@@ -0,0 +1,81 @@
+package gitlab
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	retryBaseDelay = 500 * time.Millisecond // First backoff delay, doubled on each retry
+	retryMaxDelay  = 60 * time.Second       // Longest wait, for backoff and rate limits alike
+)
+
+// WithRetries sets how many times a request is retried after a transport
+// error, a 5xx answer or a 429 rate limit. Zero, the default, disables
+// retries.
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		if n >= 0 {
+			c.maxRetries = n
+		}
+	}
+}
+
+// retryAfter reports whether the request that produced resp or err should be
+// retried after attempt (counting from 0), and how long to wait first.
+// Rate limits honour Retry-After or RateLimit-Reset; other failures back off
+// exponentially.
+func (c *Client) retryAfter(resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= c.maxRetries {
+		return 0, false
+	}
+	backoff := min(retryBaseDelay<<attempt, retryMaxDelay)
+	if err != nil {
+		return backoff, true // The request or reading its response failed
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		if wait, ok := rateLimitWait(resp.Header, time.Now()); ok {
+			return min(wait, retryMaxDelay), true
+		}
+		return backoff, true
+	case resp.StatusCode >= 500:
+		return backoff, true
+	default:
+		return 0, false
+	}
+}
+
+// rateLimitWait reads how long GitLab asks clients to wait from the
+// Retry-After (seconds) or RateLimit-Reset (Unix time) header.
+func rateLimitWait(h http.Header, now time.Time) (time.Duration, bool) {
+	if secs, err := strconv.Atoi(h.Get("Retry-After")); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if reset, err := strconv.ParseInt(h.Get("RateLimit-Reset"), 10, 64); err == nil {
+		return max(time.Unix(reset, 0).Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// endpointOf returns the API path of rawURL with IDs and paths of individual
+// groups and projects replaced by ":id", e.g. "/groups/:id/subgroups", for
+// grouping requests in logs and metrics.
+func endpointOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "unknown"
+	}
+	path := u.EscapedPath() // Keeps escaped paths like platform%2Fapi in one segment
+	if i := strings.Index(path, "/api/v4/"); i >= 0 {
+		path = path[i+len("/api/v4"):]
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) > 1 {
+		segments[1] = ":id"
+	}
+	return "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,191 @@
+package gitlab
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SortKey names an order for groups and projects.
+type SortKey string
+
+const (
+	SortPath     SortKey = "path"     // Full path, case-insensitive (the default)
+	SortName     SortKey = "name"     // Name, case-insensitive
+	SortID       SortKey = "id"       // ID, oldest first
+	SortActivity SortKey = "activity" // Most recently active first; a group's activity is its newest project's
+	SortCreated  SortKey = "created"  // Newest first
+	SortSize     SortKey = "size"     // Groups with the most projects beneath them first; projects using the most storage first
+)
+
+// SortKeys lists every sort key, in the order shown in help text.
+var SortKeys = []SortKey{SortPath, SortName, SortID, SortActivity, SortCreated, SortSize}
+
+// ParseSortKey returns the SortKey named by s.
+func ParseSortKey(s string) (SortKey, error) {
+	for _, k := range SortKeys {
+		if string(k) == strings.ToLower(s) {
+			return k, nil
+		}
+	}
+	names := make([]string, len(SortKeys))
+	for i, k := range SortKeys {
+		names[i] = string(k)
+	}
+	return "", fmt.Errorf("unknown sort key %q (want one of: %s)", s, strings.Join(names, ", "))
+}
+
+// NeedsPopulation reports whether groups are ordered by key from what lies
+// beneath them (activity and size), which only populated groups have.
+func (k SortKey) NeedsPopulation() bool {
+	return k == SortActivity || k == SortSize
+}
+
+// ProjectsInOrder reports whether ProjectsBy yields projects in key's order,
+// as SortProjects would leave them apart from ties, so they can be used as
+// they arrive. GitLab can't order by full path or size, and its name order
+// depends on the database collation.
+func ProjectsInOrder(key SortKey) bool {
+	return key == SortID || key == SortActivity || key == SortCreated
+}
+
+// GroupsInOrder is ProjectsInOrder for GroupsBy. GitLab orders groups by
+// their own path rather than the full path, so only IDs qualify.
+func GroupsInOrder(key SortKey) bool {
+	return key == SortID
+}
+
+// ComparePaths compares full paths in SortPath order, ignoring case.
+func ComparePaths(a, b string) int {
+	return compareFold(a, b)
+}
+
+// SortProjects sorts projects in place by key, reversed if reverse is set.
+// Ties are broken by path. Size is the storage reported in Statistics;
+// projects without statistics sort as if empty.
+func SortProjects(projects []Project, key SortKey, reverse bool) {
+	slices.SortStableFunc(projects, func(a, b Project) int {
+		var c int
+		switch key {
+		case SortName:
+			c = compareFold(a.Name, b.Name)
+		case SortID:
+			c = cmp.Compare(a.ID, b.ID)
+		case SortActivity:
+			c = compareNewest(a.LastActivityAt, b.LastActivityAt)
+		case SortCreated:
+			c = compareNewest(a.CreatedAt, b.CreatedAt)
+		case SortSize:
+			c = cmp.Compare(storageSize(b), storageSize(a))
+		}
+		if c == 0 {
+			c = ComparePaths(a.PathWithNamespace, b.PathWithNamespace)
+		}
+		if reverse {
+			return -c
+		}
+		return c
+	})
+}
+
+// SortGroups sorts groups in place by key, reversed if reverse is set.
+// Ties are broken by path. Activity and size come from each group's
+// populated subgroups and projects (see NeedsPopulation), so unpopulated
+// groups are left in path order by those keys.
+func SortGroups(groups []Group, key SortKey, reverse bool) {
+	var stats map[int]GroupStats
+	if key.NeedsPopulation() {
+		stats = make(map[int]GroupStats, len(groups))
+		for _, g := range groups {
+			stats[g.ID] = ComputeStats(g)
+		}
+	}
+	slices.SortStableFunc(groups, func(a, b Group) int {
+		var c int
+		switch key {
+		case SortName:
+			c = compareFold(a.Name, b.Name)
+		case SortID:
+			c = cmp.Compare(a.ID, b.ID)
+		case SortActivity:
+			c = compareNewest(stats[a.ID].LastActivity, stats[b.ID].LastActivity)
+		case SortCreated:
+			c = compareNewest(a.CreatedAt, b.CreatedAt)
+		case SortSize:
+			c = cmp.Compare(stats[b.ID].TotalProjects, stats[a.ID].TotalProjects)
+		}
+		if c == 0 {
+			c = ComparePaths(a.FullPath, b.FullPath)
+		}
+		if reverse {
+			return -c
+		}
+		return c
+	})
+}
+
+// SortHierarchy sorts the subgroups and projects at every level of a
+// populated group in place.
+func SortHierarchy(group *Group, key SortKey, reverse bool) {
+	SortGroups(group.Subgroups, key, reverse)
+	SortProjects(group.Projects, key, reverse)
+	for i := range group.Subgroups {
+		SortHierarchy(&group.Subgroups[i], key, reverse)
+	}
+}
+
+// projectOrder returns the order_by and sort parameters that make GitLab
+// list projects in key's order. Keys GitLab has no order for list the most
+// recently active projects first, whatever reverse is, so that a limited
+// listing keeps the projects people are working on.
+func projectOrder(key SortKey, reverse bool) (orderBy, sort string) {
+	asc := true
+	switch key {
+	case SortName:
+		orderBy = "name"
+	case SortID:
+		orderBy = "id"
+	case SortActivity:
+		orderBy, asc = "last_activity_at", false
+	case SortCreated:
+		orderBy, asc = "created_at", false
+	default:
+		return "last_activity_at", "desc"
+	}
+	if asc != reverse {
+		return orderBy, "asc"
+	}
+	return orderBy, "desc"
+}
+
+// groupOrder is projectOrder for GroupsBy: GitLab lists groups by name
+// unless asked for ID order.
+func groupOrder(key SortKey, reverse bool) (orderBy, sort string) {
+	if key != SortID {
+		return "name", "asc"
+	}
+	if reverse {
+		return "id", "desc"
+	}
+	return "id", "asc"
+}
+
+// storageSize returns the storage a project uses, or 0 without statistics.
+func storageSize(p Project) int64 {
+	if p.Statistics == nil {
+		return 0
+	}
+	return p.Statistics.StorageSize
+}
+
+// compareFold compares strings case-insensitively.
+func compareFold(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// compareNewest orders later times first.
+func compareNewest(a, b time.Time) int {
+	return b.Compare(a)
+}
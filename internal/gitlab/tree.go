@@ -0,0 +1,103 @@
+package gitlab
+
+import "time"
+
+// PruneOptions selects which branches PruneHierarchy keeps.
+type PruneOptions struct {
+	// KeepProject reports whether a project stays in the tree. Nil keeps all projects.
+	KeepProject func(Project) bool
+	// KeepGroup reports whether a subgroup stays in the tree even without
+	// kept descendants. Nil matches no groups.
+	KeepGroup func(Group) bool
+	// PruneEmpty drops subgroups with nothing kept beneath them.
+	PruneEmpty bool
+}
+
+// PruneHierarchy removes branches from a populated group in place. When a
+// filter is set (KeepProject or KeepGroup), only matching projects and
+// groups survive, together with every ancestor needed to reach them; subgroups
+// without matches are always dropped. The root group itself is never
+// removed; the result reports whether anything was kept beneath it.
+func PruneHierarchy(group *Group, opts PruneOptions) bool {
+	filtering := opts.KeepProject != nil || opts.KeepGroup != nil
+
+	if opts.KeepProject != nil {
+		kept := group.Projects[:0:0]
+		for _, p := range group.Projects {
+			if opts.KeepProject(p) {
+				kept = append(kept, p)
+			}
+		}
+		group.Projects = kept
+	}
+
+	subgroups := group.Subgroups[:0:0]
+	for _, sub := range group.Subgroups {
+		matched := opts.KeepGroup != nil && opts.KeepGroup(sub)
+		nonEmpty := PruneHierarchy(&sub, opts)
+		if matched || nonEmpty || (!filtering && !opts.PruneEmpty) {
+			subgroups = append(subgroups, sub)
+		}
+	}
+	group.Subgroups = subgroups
+
+	return len(group.Projects) > 0 || len(group.Subgroups) > 0
+}
+
+// Flatten returns group and its populated subgroups depth-first, and the
+// projects of all of them. Subgroups and projects fields are left as they are.
+func Flatten(group Group) ([]Group, []Project) {
+	groups := []Group{group}
+	projects := append([]Project(nil), group.Projects...)
+	for _, sub := range group.Subgroups {
+		subGroups, subProjects := Flatten(sub)
+		groups = append(groups, subGroups...)
+		projects = append(projects, subProjects...)
+	}
+	return groups, projects
+}
+
+// GroupStats summarises a populated group's descendants.
+type GroupStats struct {
+	DirectSubgroups  int       // Subgroups directly in the group
+	TotalSubgroups   int       // Subgroups at any depth
+	DirectProjects   int       // Projects directly in the group
+	TotalProjects    int       // Projects at any depth
+	ArchivedProjects int       // Archived projects at any depth
+	PrivateProjects  int       // Private projects at any depth
+	LastActivity     time.Time // Most recent project activity at any depth; zero if unknown
+}
+
+// ComputeStats counts the subgroups and projects of an already populated
+// group. Only what has been populated is counted, so a tree limited by depth
+// or pruned by a filter yields correspondingly smaller numbers.
+func ComputeStats(group Group) GroupStats {
+	stats := GroupStats{
+		DirectSubgroups: len(group.Subgroups),
+		TotalSubgroups:  len(group.Subgroups),
+		DirectProjects:  len(group.Projects),
+		TotalProjects:   len(group.Projects),
+	}
+	for _, p := range group.Projects {
+		if p.Archived {
+			stats.ArchivedProjects++
+		}
+		if p.Visibility == "private" {
+			stats.PrivateProjects++
+		}
+		if p.LastActivityAt.After(stats.LastActivity) {
+			stats.LastActivity = p.LastActivityAt
+		}
+	}
+	for _, sub := range group.Subgroups {
+		subStats := ComputeStats(sub)
+		stats.TotalSubgroups += subStats.TotalSubgroups
+		stats.TotalProjects += subStats.TotalProjects
+		stats.ArchivedProjects += subStats.ArchivedProjects
+		stats.PrivateProjects += subStats.PrivateProjects
+		if subStats.LastActivity.After(stats.LastActivity) {
+			stats.LastActivity = subStats.LastActivity
+		}
+	}
+	return stats
+}
@@ -1,26 +1,59 @@
 package gitlab
 
+import "time"
+
 // Project represents a GitLab project.
 type Project struct {
-	ID                int    `json:"id"`
-	PathWithNamespace string `json:"path_with_namespace"`
-	Name              string `json:"name"`
+	ID                int       `json:"id"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	Name              string    `json:"name"`
+	Namespace         Namespace `json:"namespace"`
+	Archived          bool      `json:"archived"`
+	Visibility        string    `json:"visibility"` // "private", "internal" or "public"
+	LastActivityAt    time.Time `json:"last_activity_at"`
+	CreatedAt         time.Time `json:"created_at"`
+	WebURL            string    `json:"web_url"`
+
+	// Statistics is only returned when requested, and only for projects
+	// the user has at least the Reporter role in.
+	Statistics *ProjectStatistics `json:"statistics,omitempty"`
+}
+
+// ProjectStatistics holds a project's storage use, in bytes.
+type ProjectStatistics struct {
+	StorageSize    int64 `json:"storage_size"`    // Everything the project stores
+	RepositorySize int64 `json:"repository_size"` // The Git repository alone
+}
+
+// Namespace is the group or user namespace a project lives in.
+type Namespace struct {
+	ID       int    `json:"id"`
+	Kind     string `json:"kind"` // "group" or "user"
+	Name     string `json:"name"`
+	FullPath string `json:"full_path"`
 }
 
 // Group represents a GitLab group or subgroup.
 type Group struct {
-	ID        int       `json:"id"`
-	ParentID  *int      `json:"parent_id"`
-	FullPath  string    `json:"full_path"`
-	Name      string    `json:"name"`
-	Subgroups []Group   `json:"-"` // Populated manually
-	Projects  []Project `json:"-"` // Populated manually
+	ID         int       `json:"id"`
+	ParentID   *int      `json:"parent_id"`
+	FullPath   string    `json:"full_path"`
+	Name       string    `json:"name"`
+	Visibility string    `json:"visibility"`
+	WebURL     string    `json:"web_url"`
+	CreatedAt  time.Time `json:"created_at"`
+	Subgroups  []Group   `json:"-"` // Populated manually
+	Projects   []Project `json:"-"` // Populated manually
 }
 
 // PaginationInfo holds information about the total resources and pagination.
+// Total and TotalPages are zero when GitLab omits them (above 10,000 items,
+// and always with keyset pagination).
 type PaginationInfo struct {
 	Total       int
 	PerPage     int
 	TotalPages  int
 	CurrentPage int
+	NextPage    int    // X-Next-Page, zero on the last page
+	NextLink    string // URL from the Link rel="next" header, empty on the last page
 }
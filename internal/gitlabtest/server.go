@@ -0,0 +1,431 @@
+// Package gitlabtest provides an in-memory fake of the parts of the GitLab
+// REST API that glids uses, for tests and local experiments. It serves
+// groups, projects, subgroups and group projects in GitLab's default order
+// or the one given by order_by and sort, with GitLab's offset and keyset
+// pagination headers, and can inject errors and latency.
+//
+//	srv := gitlabtest.NewServer()
+//	defer srv.Close()
+//	srv.AddGroup(gitlab.Group{ID: 1, FullPath: "platform", Name: "platform"})
+//	srv.AddProject(gitlab.Project{ID: 10, Name: "api", PathWithNamespace: "platform/api",
+//		Namespace: gitlab.Namespace{ID: 1, Kind: "group"}})
+//	client := srv.Client()
+package gitlabtest
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+// Token is the personal access token the fake expects from Client.
+const Token = "gitlabtest-token"
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// Server is a running fake GitLab. Its methods are safe to call while
+// requests are being served.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	groups   []gitlab.Group
+	projects []gitlab.Project
+	latency  time.Duration
+	faults   []*fault
+	requests []string
+}
+
+// fault is an injected error answer.
+type fault struct {
+	pathPrefix string // Matches requests whose path (after /api/v4) starts with this
+	status     int
+	body       string
+	header     http.Header
+	remaining  int // Requests still to fail; negative fails forever
+}
+
+// NewServer starts a fake GitLab with no groups or projects.
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Client returns a gitlab.Client talking to the fake. Retries are off
+// unless an option turns them back on.
+func (s *Server) Client(opts ...gitlab.Option) *gitlab.Client {
+	defaults := []gitlab.Option{gitlab.WithHTTPClient(s.Server.Client()), gitlab.WithRetries(0)}
+	return gitlab.NewClient(s.URL, Token, append(defaults, opts...)...)
+}
+
+// AddGroup adds groups. ParentID links subgroups to their parents.
+func (s *Server) AddGroup(groups ...gitlab.Group) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, g := range groups {
+		g.Subgroups, g.Projects = nil, nil
+		s.groups = append(s.groups, g)
+	}
+}
+
+// AddProject adds projects. Namespace.ID places a project in a group.
+// Projects without LastActivityAt count as active now.
+func (s *Server) AddProject(projects ...gitlab.Project) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range projects {
+		if p.LastActivityAt.IsZero() {
+			p.LastActivityAt = time.Now()
+		}
+		s.projects = append(s.projects, p)
+	}
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Fail makes the next times requests whose API path starts with pathPrefix
+// (e.g. "/groups/3/projects", or "/" for all) answer with status and a
+// GitLab-style error message. A negative times fails them forever. Faults
+// are checked in the order they were added.
+func (s *Server) Fail(pathPrefix string, status, times int) {
+	s.FailWithHeader(pathPrefix, status, times, nil)
+}
+
+// FailWithHeader is like Fail but also sets header on the error answers,
+// e.g. Retry-After for a 429.
+func (s *Server) FailWithHeader(pathPrefix string, status, times int, header http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := json.Marshal(map[string]string{"message": fmt.Sprintf("%d %s", status, http.StatusText(status))})
+	s.faults = append(s.faults, &fault{pathPrefix: pathPrefix, status: status, body: string(body), header: header, remaining: times})
+}
+
+// Requests returns the path and query of every request received so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// serve answers one API request.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.URL.RequestURI())
+	latency := s.latency
+	s.mu.Unlock()
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+Token && r.Header.Get("Private-Token") != Token {
+		writeError(w, http.StatusUnauthorized, nil, `{"message":"401 Unauthorized"}`)
+		return
+	}
+	path, ok := strings.CutPrefix(r.URL.EscapedPath(), "/api/v4")
+	if !ok || r.Method != http.MethodGet {
+		writeError(w, http.StatusNotFound, nil, `{"message":"404 Not Found"}`)
+		return
+	}
+	if f := s.takeFault(path); f != nil {
+		writeError(w, f.status, f.header, f.body)
+		return
+	}
+
+	query := r.URL.Query()
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(segments) == 1 && segments[0] == "groups":
+		writePage(w, r, s.listGroups(query, nil), groupOrder)
+	case len(segments) == 1 && segments[0] == "projects":
+		writePage(w, r, s.listProjects(query, nil), projectOrder)
+	case len(segments) == 2 && segments[0] == "groups":
+		group, ok := s.findGroup(segments[1])
+		writeOne(w, group, ok)
+	case len(segments) == 2 && segments[0] == "projects":
+		project, ok := s.findProject(segments[1])
+		writeOne(w, project, ok)
+	case len(segments) == 3 && segments[0] == "groups" && segments[2] == "subgroups":
+		parent, ok := s.findGroup(segments[1])
+		if !ok {
+			writeError(w, http.StatusNotFound, nil, `{"message":"404 Group Not Found"}`)
+			return
+		}
+		writePage(w, r, s.listGroups(query, &parent.ID), groupOrder)
+	case len(segments) == 3 && segments[0] == "groups" && segments[2] == "projects":
+		group, ok := s.findGroup(segments[1])
+		if !ok {
+			writeError(w, http.StatusNotFound, nil, `{"message":"404 Group Not Found"}`)
+			return
+		}
+		writePage(w, r, s.listProjects(query, &group.ID), projectOrder)
+	default:
+		writeError(w, http.StatusNotFound, nil, `{"message":"404 Not Found"}`)
+	}
+}
+
+// takeFault returns the first fault matching path and uses it up.
+func (s *Server) takeFault(path string) *fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.faults {
+		if f.remaining != 0 && strings.HasPrefix(path, f.pathPrefix) {
+			if f.remaining > 0 {
+				f.remaining--
+			}
+			return f
+		}
+	}
+	return nil
+}
+
+// item is a group or project in a listing, with the fields it can be
+// ordered by. id is also used for keyset paging.
+type item struct {
+	id       int
+	name     string
+	path     string // The item's own path, not the full path
+	created  time.Time
+	activity time.Time
+	value    any
+}
+
+// ordering is the order_by and sort a listing uses when the request gives none.
+type ordering struct {
+	by, sort string
+}
+
+var (
+	// GitLab lists groups by name and projects newest first by default.
+	groupOrder   = ordering{by: "name", sort: "asc"}
+	projectOrder = ordering{by: "created_at", sort: "desc"}
+)
+
+// listGroups returns the groups matching query, in no particular order. A
+// non-nil parent restricts them to its direct subgroups.
+func (s *Server) listGroups(query url.Values, parent *int) []item {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	search := strings.ToLower(query.Get("search"))
+	var items []item
+	for _, g := range s.groups {
+		if parent != nil && (g.ParentID == nil || *g.ParentID != *parent) {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(g.Name), search) && !strings.Contains(strings.ToLower(g.FullPath), search) {
+			continue
+		}
+		items = append(items, item{id: g.ID, name: g.Name, path: path.Base(g.FullPath), created: g.CreatedAt, value: g})
+	}
+	return items
+}
+
+// listProjects returns the projects matching query, in no particular order.
+// A non-nil group restricts them to the projects directly in it.
+func (s *Server) listProjects(query url.Values, group *int) []item {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	search := strings.ToLower(query.Get("search"))
+	var after time.Time
+	if v := query.Get("last_activity_after"); v != "" {
+		after, _ = time.Parse(time.RFC3339, v)
+	}
+	var items []item
+	for _, p := range s.projects {
+		if group != nil && (p.Namespace.Kind != "group" || p.Namespace.ID != *group) {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(p.Name), search) && !strings.Contains(strings.ToLower(p.PathWithNamespace), search) {
+			continue
+		}
+		if !after.IsZero() && !p.LastActivityAt.After(after) {
+			continue
+		}
+		if query.Get("statistics") != "true" {
+			p.Statistics = nil // Only sent when asked for
+		}
+		items = append(items, item{id: p.ID, name: p.Name, path: path.Base(p.PathWithNamespace),
+			created: p.CreatedAt, activity: p.LastActivityAt, value: p})
+	}
+	return items
+}
+
+// sortItems orders items by the request's order_by and sort parameters,
+// or def without them, breaking ties by ID. It reports an error message for
+// orders GitLab would reject: unknown keys, and keyset pagination in any
+// order but ascending ID.
+func sortItems(items []item, query url.Values, def ordering) ([]item, string) {
+	order := def
+	if v := query.Get("order_by"); v != "" {
+		order = ordering{by: v, sort: "desc"}
+	}
+	if v := query.Get("sort"); v != "" {
+		order.sort = v
+	}
+	if order.sort != "asc" && order.sort != "desc" {
+		return nil, "sort does not have a valid value"
+	}
+	if query.Get("pagination") == "keyset" && (order.by != "id" || order.sort != "asc") {
+		return nil, "Keyset pagination is only supported with order_by=id&sort=asc"
+	}
+	var compare func(a, b item) int
+	switch order.by {
+	case "id":
+		compare = func(a, b item) int { return 0 }
+	case "name":
+		compare = func(a, b item) int { return strings.Compare(a.name, b.name) }
+	case "path":
+		compare = func(a, b item) int { return strings.Compare(a.path, b.path) }
+	case "created_at":
+		compare = func(a, b item) int { return a.created.Compare(b.created) }
+	case "last_activity_at", "updated_at":
+		if def != projectOrder {
+			return nil, "order_by does not have a valid value"
+		}
+		compare = func(a, b item) int { return a.activity.Compare(b.activity) }
+	default:
+		return nil, "order_by does not have a valid value"
+	}
+	slices.SortFunc(items, func(a, b item) int {
+		c := compare(a, b)
+		if c == 0 {
+			c = cmp.Compare(a.id, b.id)
+		}
+		if order.sort == "desc" {
+			return -c
+		}
+		return c
+	})
+	return items, ""
+}
+
+// findGroup returns the group with the given ID or URL-escaped full path.
+func (s *Server) findGroup(ref string) (gitlab.Group, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ref, _ = url.PathUnescape(ref)
+	for _, g := range s.groups {
+		if strconv.Itoa(g.ID) == ref || g.FullPath == ref {
+			return g, true
+		}
+	}
+	return gitlab.Group{}, false
+}
+
+// findProject returns the project with the given ID or URL-escaped full path.
+func (s *Server) findProject(ref string) (gitlab.Project, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ref, _ = url.PathUnescape(ref)
+	for _, p := range s.projects {
+		if strconv.Itoa(p.ID) == ref || p.PathWithNamespace == ref {
+			return p, true
+		}
+	}
+	return gitlab.Project{}, false
+}
+
+// writeOne writes a single group or project, or a 404.
+func writeOne(w http.ResponseWriter, v any, found bool) {
+	if !found {
+		writeError(w, http.StatusNotFound, nil, `{"message":"404 Not Found"}`)
+		return
+	}
+	writeJSON(w, v)
+}
+
+// writePage sorts items (see sortItems) and writes one page of them.
+// Keyset requests (pagination=keyset) page with id_after and get a Link
+// rel="next" header; others page with page and get GitLab's X-Total,
+// X-Total-Pages, X-Page, X-Per-Page and X-Next-Page headers.
+func writePage(w http.ResponseWriter, r *http.Request, items []item, def ordering) {
+	query := r.URL.Query()
+	items, msg := sortItems(items, query, def)
+	if msg != "" {
+		body, _ := json.Marshal(map[string]string{"error": msg})
+		writeError(w, http.StatusBadRequest, nil, string(body))
+		return
+	}
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	perPage = min(perPage, maxPerPage)
+
+	var page []item
+	if query.Get("pagination") == "keyset" {
+		idAfter, _ := strconv.Atoi(query.Get("id_after"))
+		start := sort.Search(len(items), func(i int) bool { return items[i].id > idAfter })
+		end := min(start+perPage, len(items))
+		page = items[start:end]
+		if end < len(items) {
+			next := *r.URL
+			q := next.Query()
+			q.Set("id_after", strconv.Itoa(page[len(page)-1].id))
+			next.RawQuery = q.Encode()
+			next.Scheme, next.Host = "http", r.Host
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+		}
+	} else {
+		current, err := strconv.Atoi(query.Get("page"))
+		if err != nil || current < 1 {
+			current = 1
+		}
+		totalPages := max((len(items)+perPage-1)/perPage, 1)
+		start := min((current-1)*perPage, len(items))
+		end := min(start+perPage, len(items))
+		page = items[start:end]
+		h := w.Header()
+		h.Set("X-Total", strconv.Itoa(len(items)))
+		h.Set("X-Total-Pages", strconv.Itoa(totalPages))
+		h.Set("X-Page", strconv.Itoa(current))
+		h.Set("X-Per-Page", strconv.Itoa(perPage))
+		if current < totalPages {
+			h.Set("X-Next-Page", strconv.Itoa(current+1))
+		} else {
+			h.Set("X-Next-Page", "")
+		}
+	}
+
+	values := make([]any, len(page))
+	for i, it := range page {
+		values[i] = it.value
+	}
+	writeJSON(w, values)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, header http.Header, body string) {
+	for k, vs := range header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, body)
+}
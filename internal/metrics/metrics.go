@@ -0,0 +1,132 @@
+// Package metrics keeps counters and gauges and writes them in the
+// Prometheus text exposition format, without third-party modules.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metric families in registration order. It is safe for
+// concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+}
+
+// family is one metric name with its labelled series.
+type family struct {
+	name   string
+	help   string
+	kind   string // "counter" or "gauge"
+	labels []string
+	series map[string]float64 // Values keyed by formatted label set, e.g. `{status="200"}`
+}
+
+// Counter is a monotonically increasing metric, optionally split by labels.
+type Counter struct {
+	r *Registry
+	f *family
+}
+
+// Gauge is a metric that can go up and down, optionally split by labels.
+type Gauge struct {
+	r *Registry
+	f *family
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(name, help, kind string, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := &family{name: name, help: help, kind: kind, labels: labels, series: make(map[string]float64)}
+	r.families = append(r.families, f)
+	return f
+}
+
+// Counter registers a counter. Values passed to Add must match labels in
+// number and order.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r: r, f: r.register(name, help, "counter", labels)}
+}
+
+// Gauge registers a gauge. Values passed to Set must match labels in
+// number and order.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r: r, f: r.register(name, help, "gauge", labels)}
+}
+
+// Add increases the series for the given label values by v, which must
+// not be negative.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter " + c.f.name + " cannot decrease")
+	}
+	c.r.update(c.f, labelValues, func(old float64) float64 { return old + v })
+}
+
+// Inc increases the series for the given label values by one.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Set sets the series for the given label values to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.r.update(g.f, labelValues, func(float64) float64 { return v })
+}
+
+func (r *Registry) update(f *family, labelValues []string, fn func(old float64) float64) {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+	key := formatLabels(f.labels, labelValues)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f.series[key] = fn(f.series[key])
+}
+
+// labelEscaper escapes label values as the exposition format requires.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// WriteTo writes every family with at least one series in the text
+// exposition format. Series are sorted by label set so output is stable.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	var b strings.Builder
+	for _, f := range r.families {
+		if len(f.series) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "%s%s %s\n", f.name, key, strconv.FormatFloat(f.series[key], 'g', -1, 64))
+		}
+	}
+	r.mu.Unlock()
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
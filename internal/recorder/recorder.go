@@ -0,0 +1,238 @@
+// Package recorder saves GitLab API traffic to a directory and serves it back
+// later, so a glids run can be reproduced offline. Both directions are
+// http.RoundTrippers, to be installed with gitlab.WithHTTPClient.
+//
+// Each exchange is stored as two files named after a hash of the request
+// and its sequence number: NAME.json holds the request and the response
+// status and headers, NAME.body the response body exactly as received.
+package recorder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// scrubbedHeaders are request headers never written to disk.
+var scrubbedHeaders = []string{"Authorization", "Private-Token", "Job-Token"}
+
+// scrubbedResponseHeaders are response headers never written to disk:
+// session cookies are as good as a token.
+var scrubbedResponseHeaders = []string{"Set-Cookie"}
+
+// volatileParams are query parameters whose values change from run to run
+// (last_activity_after is derived from the current time) and are ignored
+// when matching a request to a recording.
+var volatileParams = []string{"last_activity_after"}
+
+// exchange is the metadata stored for one request and its response.
+type exchange struct {
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestHeader  http.Header `json:"request_header,omitempty"`
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"response_header"`
+}
+
+// matchKey returns the part of req that identifies it in a recording: the
+// method and the path and query, without host or volatile parameters.
+func matchKey(req *http.Request) string {
+	query := req.URL.Query()
+	for _, p := range volatileParams {
+		if query.Has(p) {
+			query.Set(p, "*")
+		}
+	}
+	return req.Method + " " + req.URL.EscapedPath() + "?" + query.Encode()
+}
+
+// sequence hands out the next sequence number per key, so a request made
+// twice is stored (and replayed) twice.
+type sequence struct {
+	mu   sync.Mutex
+	next map[string]int
+}
+
+func (s *sequence) take(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next == nil {
+		s.next = make(map[string]int)
+	}
+	n := s.next[key]
+	s.next[key] = n + 1
+	return n
+}
+
+// fileName returns the base name of the files for the n-th request with key.
+func fileName(key string, n int) string {
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%s-%03d", hex.EncodeToString(sum[:8]), n)
+}
+
+// Recorder is an http.RoundTripper that passes requests on to Next and
+// saves every exchange in Dir.
+type Recorder struct {
+	Dir  string
+	Next http.RoundTripper // http.DefaultTransport if nil
+	seq  sequence
+}
+
+// NewRecorder creates dir if needed and returns a Recorder writing to it.
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Recorder{Dir: dir}, nil
+}
+
+// RoundTrip performs req and records it. The response body is read fully
+// so it can be saved, and handed back unchanged.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := r.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	ex := exchange{
+		Method:         req.Method,
+		URL:            redactURL(req.URL),
+		RequestHeader:  scrub(req.Header, scrubbedHeaders),
+		Status:         resp.StatusCode,
+		ResponseHeader: scrub(resp.Header, scrubbedResponseHeaders),
+	}
+	key := matchKey(req)
+	name := filepath.Join(r.Dir, fileName(key, r.seq.take(key)))
+	if err := r.save(name, ex, body); err != nil {
+		return nil, fmt.Errorf("recording %s: %w", req.URL, err)
+	}
+	return resp, nil
+}
+
+// scrub returns a copy of header with the values of names replaced.
+func scrub(header http.Header, names []string) http.Header {
+	header = header.Clone()
+	for _, h := range names {
+		if header.Get(h) != "" {
+			header.Set(h, "REDACTED")
+		}
+	}
+	return header
+}
+
+func (r *Recorder) save(name string, ex exchange, body []byte) error {
+	meta, err := json.MarshalIndent(ex, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(name+".json", append(meta, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(name+".body", body, 0o644)
+}
+
+// Replayer is an http.RoundTripper that answers requests from a directory
+// written by a Recorder, without any network access. Requests match on
+// method, path and query, ignoring the host and volatile parameters, and
+// the n-th identical request gets the n-th recorded answer. A request that
+// was never recorded, or is made more often than recorded, is an error
+// unless Lenient is set.
+type Replayer struct {
+	Dir     string
+	Lenient bool // Answer requests made more often than recorded with the last recorded answer
+	seq     sequence
+}
+
+// NewReplayer returns a Replayer reading from dir, which must exist.
+func NewReplayer(dir string) (*Replayer, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return &Replayer{Dir: dir}, nil
+}
+
+// RoundTrip returns the recorded response to req, or an error naming the
+// request if there is none left to give.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := matchKey(req)
+	n := r.seq.take(key)
+	for i := n; i >= 0; i-- {
+		name := filepath.Join(r.Dir, fileName(key, i))
+		meta, err := os.ReadFile(name + ".json")
+		if os.IsNotExist(err) {
+			if !r.Lenient {
+				break
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var ex exchange
+		if err := json.Unmarshal(meta, &ex); err != nil {
+			return nil, fmt.Errorf("reading %s.json: %w", name, err)
+		}
+		body, err := os.ReadFile(name + ".body")
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", ex.Status, http.StatusText(ex.Status)),
+			StatusCode:    ex.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        ex.ResponseHeader,
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	if times := r.recorded(key, n); times > 0 {
+		return nil, fmt.Errorf("%s %s was made more times than the %d recorded", req.Method, redactURL(req.URL), times)
+	}
+	return nil, fmt.Errorf("no recorded response for %s %s", req.Method, redactURL(req.URL))
+}
+
+// recorded returns how many of the first n requests with key were recorded.
+func (r *Replayer) recorded(key string, n int) int {
+	for i := range n {
+		if _, err := os.Stat(filepath.Join(r.Dir, fileName(key, i)+".json")); err != nil {
+			return i
+		}
+	}
+	return n
+}
+
+// redactURL returns u as a string without any private_token parameter.
+func redactURL(u *url.URL) string {
+	c := *u
+	query := c.Query()
+	if query.Has("private_token") {
+		query.Set("private_token", "REDACTED")
+		c.RawQuery = query.Encode()
+	}
+	return c.String()
+}
@@ -0,0 +1,217 @@
+package recorder
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bboles/glids/internal/gitlab"
+	"github.com/bboles/glids/internal/gitlabtest"
+)
+
+var update = flag.Bool("update", false, "re-record testdata from the fake GitLab")
+
+// fakeGitLab returns a fake GitLab with two groups and three projects.
+func fakeGitLab(t *testing.T) *gitlabtest.Server {
+	t.Helper()
+	fake := gitlabtest.NewServer()
+	t.Cleanup(fake.Close)
+	platform := 1
+	active := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	fake.AddGroup(
+		gitlab.Group{ID: platform, Name: "platform", FullPath: "platform"},
+		gitlab.Group{ID: 2, ParentID: &platform, Name: "teams", FullPath: "platform/teams"},
+	)
+	fake.AddProject(
+		gitlab.Project{ID: 10, Name: "api", PathWithNamespace: "platform/api", Namespace: gitlab.Namespace{ID: platform, Kind: "group"}, LastActivityAt: active},
+		gitlab.Project{ID: 11, Name: "web", PathWithNamespace: "platform/web", Namespace: gitlab.Namespace{ID: platform, Kind: "group"}, LastActivityAt: active},
+		gitlab.Project{ID: 20, Name: "cli", PathWithNamespace: "platform/teams/cli", Namespace: gitlab.Namespace{ID: 2, Kind: "group"}, LastActivityAt: active},
+	)
+	return fake
+}
+
+// record runs fn against the fake through a Recorder writing to dir.
+func record(t *testing.T, fake *gitlabtest.Server, dir string, fn func(*gitlab.Client)) {
+	t.Helper()
+	rec, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Next = fake.Server.Client().Transport
+	fn(fake.Client(gitlab.WithHTTPClient(&http.Client{Transport: rec})))
+}
+
+// replayClient returns a client answered by rep. The host is never contacted.
+func replayClient(rep *Replayer) *gitlab.Client {
+	return gitlab.NewClient("http://replay.invalid", "replay",
+		gitlab.WithHTTPClient(&http.Client{Transport: rep}), gitlab.WithRetries(0))
+}
+
+func projectPaths(projects []gitlab.Project) []string {
+	var paths []string
+	for _, p := range projects {
+		paths = append(paths, p.PathWithNamespace)
+	}
+	return paths
+}
+
+func TestRecordScrubsTokens(t *testing.T) {
+	dir := t.TempDir()
+	record(t, fakeGitLab(t), dir, func(c *gitlab.Client) {
+		if _, err := c.GetGroup("platform"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("recorded %d exchanges, want 1", len(files))
+	}
+	meta, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(meta), gitlabtest.Token) {
+		t.Errorf("recording contains the token:\n%s", meta)
+	}
+	if !strings.Contains(string(meta), "REDACTED") {
+		t.Errorf("recording has no redacted header:\n%s", meta)
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestRecordScrubsCookies(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Next = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		header := http.Header{"Content-Type": {"application/json"}}
+		header.Add("Set-Cookie", "_gitlab_session=s3cr3t; path=/; HttpOnly")
+		header.Add("Set-Cookie", "known_sign_in=s3cr3t; path=/")
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(strings.NewReader("{}")), Request: req}, nil
+	})
+	req, _ := http.NewRequest(http.MethodGet, "https://gitlab.example.com/api/v4/groups/1", nil)
+	resp, err := rec.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Header.Values("Set-Cookie"); len(got) != 2 {
+		t.Errorf("caller got Set-Cookie %q, want both cookies untouched", got)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("recorded %d exchanges, want 1", len(files))
+	}
+	meta, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(meta), "s3cr3t") {
+		t.Errorf("recording contains a session cookie:\n%s", meta)
+	}
+	if !strings.Contains(string(meta), `"Set-Cookie": [
+      "REDACTED"
+    ]`) {
+		t.Errorf("recording has no redacted Set-Cookie:\n%s", meta)
+	}
+}
+
+// TestReplay replays testdata/projects, recorded from fakeGitLab with
+// GetProjects("platform", true). Run with -update after changing the fake
+// or the requests the client makes.
+func TestReplay(t *testing.T) {
+	dir := filepath.Join("testdata", "projects")
+	if *update {
+		os.RemoveAll(dir)
+		record(t, fakeGitLab(t), dir, func(c *gitlab.Client) {
+			if _, err := c.GetProjects("platform", true); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	rep, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := replayClient(rep)
+	projects, err := client.GetProjects("platform", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"platform/api", "platform/web", "platform/teams/cli"}
+	if got := projectPaths(projects); !slices.Equal(got, want) {
+		t.Errorf("replayed projects = %v, want %v", got, want)
+	}
+
+	// Every recorded answer has been used up
+	if _, err := client.GetProjects("platform", true); err == nil || !strings.Contains(err.Error(), "more times than the 1 recorded") {
+		t.Errorf("repeated GetProjects = %v, want an exhausted recording error", err)
+	}
+	if _, err := client.GetGroups("platform", true); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("GetGroups = %v, want an unrecorded request error", err)
+	}
+}
+
+func TestReplayLenient(t *testing.T) {
+	dir := t.TempDir()
+	record(t, fakeGitLab(t), dir, func(c *gitlab.Client) {
+		if _, err := c.GetProject("platform/api"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	rep, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep.Lenient = true
+	client := replayClient(rep)
+	for range 3 {
+		p, err := client.GetProject("platform/api")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.ID != 10 {
+			t.Errorf("GetProject = %d, want 10", p.ID)
+		}
+	}
+	// Lenient mode still refuses requests that were never recorded
+	if _, err := client.GetProject("platform/web"); err == nil {
+		t.Error("GetProject of an unrecorded path succeeded")
+	}
+}
+
+func TestReplayRepeatedRequestsInOrder(t *testing.T) {
+	fake := fakeGitLab(t)
+	dir := t.TempDir()
+	record(t, fake, dir, func(c *gitlab.Client) {
+		c.GetGroup("platform")
+		fake.Fail("/groups/platform", http.StatusNotFound, 1)
+		c.GetGroup("platform")
+	})
+
+	rep, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := replayClient(rep)
+	if _, err := client.GetGroup("platform"); err != nil {
+		t.Errorf("first GetGroup = %v, want the recorded success", err)
+	}
+	if _, err := client.GetGroup("platform"); !gitlab.IsNotFound(err) {
+		t.Errorf("second GetGroup = %v, want the recorded 404", err)
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bboles/glids/internal/gitlab"
+	"github.com/bboles/glids/internal/metrics"
+)
+
+// Metrics counts GitLab API traffic, cache use and refreshes. It is a
+// gitlab.Observer: registering it on the client with gitlab.WithObserver
+// counts every request the client makes, wherever it comes from.
+type Metrics struct {
+	registry *metrics.Registry
+
+	apiRequests     *metrics.Counter
+	apiRetries      *metrics.Counter
+	rateLimitWaits  *metrics.Counter
+	rateLimitWaited *metrics.Counter
+	httpRequests    *metrics.Counter
+	cacheLookups    *metrics.Counter
+	refreshes       *metrics.Counter
+	refreshDuration *metrics.Gauge
+	lastRefresh     *metrics.Gauge
+	inventory       *metrics.Gauge
+}
+
+// NewMetrics registers the glids metrics in a new registry.
+func NewMetrics() *Metrics {
+	r := metrics.NewRegistry()
+	return &Metrics{
+		registry:        r,
+		apiRequests:     r.Counter("glids_gitlab_requests_total", "GitLab API requests by endpoint and HTTP status (0 if no response).", "endpoint", "status"),
+		apiRetries:      r.Counter("glids_gitlab_retries_total", "GitLab API requests retried after an error or rate limit.", "endpoint"),
+		rateLimitWaits:  r.Counter("glids_gitlab_rate_limit_waits_total", "Times GitLab answered 429 and the client waited."),
+		rateLimitWaited: r.Counter("glids_gitlab_rate_limit_wait_seconds_total", "Time spent waiting for GitLab rate limits to reset."),
+		httpRequests:    r.Counter("glids_http_requests_total", "Requests served by route and HTTP status.", "route", "status"),
+		cacheLookups:    r.Counter("glids_cache_lookups_total", "Lookups answered from the cache (hit) or from GitLab (miss).", "result"),
+		refreshes:       r.Counter("glids_refreshes_total", "Cache refreshes by result.", "result"),
+		refreshDuration: r.Gauge("glids_refresh_duration_seconds", "Duration of the last successful cache refresh."),
+		lastRefresh:     r.Gauge("glids_last_refresh_timestamp_seconds", "Unix time of the last successful cache refresh."),
+		inventory:       r.Gauge("glids_inventory_items", "Groups and projects in the cache.", "kind"),
+	}
+}
+
+// HandleEvent counts API requests, retries and rate-limit waits.
+func (m *Metrics) HandleEvent(e gitlab.Event) {
+	switch e.Kind {
+	case gitlab.RequestCompleted:
+		m.apiRequests.Inc(e.Endpoint, strconv.Itoa(e.StatusCode))
+	case gitlab.RequestRetried:
+		m.apiRetries.Inc(e.Endpoint)
+	case gitlab.RateLimited:
+		m.rateLimitWaits.Inc()
+		m.rateLimitWaited.Add(e.Duration.Seconds())
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.registry.WriteTo(w)
+}
+
+// cacheLookup counts a lookup answered from the cache or not.
+func (m *Metrics) cacheLookup(hit bool) {
+	if m == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheLookups.Inc(result)
+}
+
+// refreshed records the outcome of a cache refresh.
+func (m *Metrics) refreshed(snap *snapshot, took time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.refreshes.Inc("error")
+		return
+	}
+	m.refreshes.Inc("success")
+	m.refreshDuration.Set(took.Seconds())
+	m.lastRefresh.Set(float64(snap.loadedAt.Unix()))
+	m.inventory.Set(float64(len(snap.groups)), "group")
+	m.inventory.Set(float64(len(snap.projects)), "project")
+}
+
+// served counts a request handled by the route pattern.
+func (m *Metrics) served(route string, status int) {
+	if m == nil {
+		return
+	}
+	if route == "" {
+		route = "unmatched"
+	}
+	m.httpRequests.Inc(route, strconv.Itoa(status))
+}
@@ -0,0 +1,427 @@
+// Package server implements "glids serve", a small JSON API that resolves
+// GitLab group and project paths to IDs and back from an in-memory cache.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bboles/glids/internal/display"
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+// LoadFunc fetches the groups and projects to serve.
+type LoadFunc func() ([]gitlab.Group, []gitlab.Project, error)
+
+// Server answers lookups from a cache of groups and projects that is
+// replaced wholesale on every refresh. It is an http.Handler, so it can be
+// exercised with net/http/httptest.
+type Server struct {
+	client  *gitlab.Client // Used for lookups that miss the cache
+	load    LoadFunc
+	logger  *log.Logger
+	mux     *http.ServeMux
+	cache   atomic.Pointer[snapshot]
+	metrics *Metrics // Nil unless WithMetrics is given
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithLogger sets the logger for requests and refreshes. The default discards them.
+func WithLogger(logger *log.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithMetrics records cache, refresh and request metrics in m and serves
+// them on /metrics. Register m on the client too, with gitlab.WithObserver,
+// to count GitLab API requests.
+func WithMetrics(m *Metrics) Option {
+	return func(s *Server) {
+		s.metrics = m
+	}
+}
+
+// New creates a Server that fills its cache with load and falls back to
+// client when a path or ID is not cached. The cache is empty, and /readyz
+// fails, until Refresh or Run has loaded it once.
+func New(client *gitlab.Client, load LoadFunc, opts ...Option) *Server {
+	s := &Server{
+		client: client,
+		load:   load,
+		logger: log.New(io.Discard, "", 0),
+		mux:    http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.mux.HandleFunc("GET /readyz", s.handleReady)
+	s.mux.HandleFunc("GET /resolve", s.handleResolve)
+	s.mux.HandleFunc("GET /id/{id}", s.handleID)
+	s.mux.HandleFunc("GET /search", s.handleSearch)
+	s.mux.HandleFunc("GET /tree/{group...}", s.handleTree)
+	if s.metrics != nil {
+		s.mux.Handle("GET /metrics", s.metrics)
+	}
+	return s
+}
+
+// Refresh loads the groups and projects and replaces the cache. On error
+// the previous cache is kept.
+func (s *Server) Refresh() error {
+	start := time.Now()
+	groups, projects, err := s.load()
+	if err != nil {
+		s.metrics.refreshed(nil, 0, err)
+		return err
+	}
+	snap := newSnapshot(groups, projects, time.Now())
+	s.cache.Store(snap)
+	s.metrics.refreshed(snap, time.Since(start), nil)
+	s.logger.Printf("Cache refreshed: %d groups, %d projects in %s", len(groups), len(projects), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// Run refreshes the cache immediately and then every interval until ctx
+// is done. Failed refreshes are logged and retried at the next interval.
+func (s *Server) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Refresh(); err != nil {
+			s.logger.Printf("Cache refresh failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ServeHTTP logs each request and dispatches it to the API.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	s.mux.ServeHTTP(rec, r)
+	_, route := s.mux.Handler(r)
+	s.metrics.served(route, rec.status)
+	s.logger.Printf("%s %s %d %s", r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Microsecond))
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// snapshot is an immutable, indexed copy of the served groups and projects,
+// plus the paths GitLab has since said do not exist. Those are forgotten
+// with the snapshot at the next refresh, so newly created items resolve
+// within one refresh interval.
+type snapshot struct {
+	loadedAt      time.Time
+	notFound      sync.Map         // Lower-cased paths that GitLab answered 404 for
+	notFoundCount atomic.Int64     // Entries in notFound, at most maxNotFound
+	records       []display.Record // Every group and project, sorted by path
+	byPath        map[string]display.Record
+	groups        map[int]gitlab.Group
+	projects      map[int]gitlab.Project
+	subgroups     map[int][]int // Group ID to the IDs of its direct subgroups
+	groupProjects map[int][]int // Group ID to the IDs of its direct projects
+}
+
+// maxNotFound caps the paths a snapshot remembers as missing, so clients
+// asking for arbitrary paths can't grow it without bound. Once it is full,
+// further missing paths are looked up on GitLab every time.
+var maxNotFound int64 = 10000
+
+// rememberNotFound records that GitLab has no group or project at key,
+// unless maxNotFound paths are already remembered.
+func (snap *snapshot) rememberNotFound(key string) {
+	if snap.notFoundCount.Add(1) > maxNotFound {
+		snap.notFoundCount.Add(-1)
+		return
+	}
+	if _, loaded := snap.notFound.LoadOrStore(key, true); loaded {
+		snap.notFoundCount.Add(-1)
+	}
+}
+
+func newSnapshot(groups []gitlab.Group, projects []gitlab.Project, loadedAt time.Time) *snapshot {
+	snap := &snapshot{
+		loadedAt:      loadedAt,
+		byPath:        make(map[string]display.Record),
+		groups:        make(map[int]gitlab.Group, len(groups)),
+		projects:      make(map[int]gitlab.Project, len(projects)),
+		subgroups:     make(map[int][]int),
+		groupProjects: make(map[int][]int),
+	}
+	for _, g := range groups {
+		if _, dup := snap.groups[g.ID]; dup {
+			continue
+		}
+		g.Subgroups, g.Projects = nil, nil
+		snap.groups[g.ID] = g
+		rec := display.GroupRecord(g)
+		snap.records = append(snap.records, rec)
+		snap.byPath[strings.ToLower(g.FullPath)] = rec
+		if g.ParentID != nil {
+			snap.subgroups[*g.ParentID] = append(snap.subgroups[*g.ParentID], g.ID)
+		}
+	}
+	for _, p := range projects {
+		if _, dup := snap.projects[p.ID]; dup {
+			continue
+		}
+		snap.projects[p.ID] = p
+		rec := display.ProjectRecord(p)
+		snap.records = append(snap.records, rec)
+		snap.byPath[strings.ToLower(p.PathWithNamespace)] = rec
+		if rec.ParentID != nil {
+			snap.groupProjects[*rec.ParentID] = append(snap.groupProjects[*rec.ParentID], p.ID)
+		}
+	}
+	slices.SortStableFunc(snap.records, func(a, b display.Record) int {
+		return gitlab.ComparePaths(a.Path, b.Path)
+	})
+	return snap
+}
+
+// tree assembles the cached subgroups and projects beneath the group with id.
+func (snap *snapshot) tree(id int) gitlab.Group {
+	g := snap.groups[id]
+	for _, subID := range snap.subgroups[id] {
+		g.Subgroups = append(g.Subgroups, snap.tree(subID))
+	}
+	for _, projectID := range snap.groupProjects[id] {
+		g.Projects = append(g.Projects, snap.projects[projectID])
+	}
+	gitlab.SortGroups(g.Subgroups, gitlab.SortName, false)
+	gitlab.SortProjects(g.Projects, gitlab.SortName, false)
+	return g
+}
+
+// errNotReady is returned by lookups before the cache has loaded.
+var errNotReady = errors.New("cache not loaded yet")
+
+// snapshot returns the current cache, or errNotReady.
+func (s *Server) snapshot() (*snapshot, error) {
+	snap := s.cache.Load()
+	if snap == nil {
+		return nil, errNotReady
+	}
+	return snap, nil
+}
+
+// writeJSON writes v as the JSON response body with the given status.
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Printf("Error writing response: %v", err)
+	}
+}
+
+// writeError writes err as a JSON error body with the given status.
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	s.writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	snap, err := s.snapshot()
+	if err != nil {
+		s.writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"status":    "ready",
+		"loaded_at": snap.loadedAt.UTC().Format(time.RFC3339),
+		"groups":    len(snap.groups),
+		"projects":  len(snap.projects),
+	})
+}
+
+// handleResolve returns the group or project at ?path=. Paths missing from
+// the cache are looked up on GitLab, so items outside the cached selection
+// (e.g. inactive ones) still resolve; paths GitLab doesn't know are
+// answered 404 from the cache until the next refresh.
+func (s *Server) handleResolve(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Query().Get("path"), "/")
+	if path == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("missing path parameter"))
+		return
+	}
+	snap, err := s.snapshot()
+	if err != nil {
+		s.writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	key := strings.ToLower(path)
+	rec, ok := snap.byPath[key]
+	_, missing := snap.notFound.Load(key)
+	s.metrics.cacheLookup(ok || missing)
+	if ok {
+		s.writeJSON(w, http.StatusOK, rec)
+		return
+	}
+	if !missing {
+		rec, err = s.lookup(path)
+		if gitlab.IsNotFound(err) {
+			snap.rememberNotFound(key) // Don't ask GitLab again until the next refresh
+			missing = true
+		}
+	}
+	if missing {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("no group or project %q", path))
+		return
+	}
+	if err != nil {
+		s.writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, rec)
+}
+
+// lookup fetches the project, or failing that the group, identified by
+// idOrPath from GitLab.
+func (s *Server) lookup(idOrPath string) (display.Record, error) {
+	project, err := s.client.GetProject(idOrPath)
+	if err == nil {
+		return display.ProjectRecord(project), nil
+	}
+	if !gitlab.IsNotFound(err) {
+		return display.Record{}, err
+	}
+	group, err := s.client.GetGroup(idOrPath)
+	if err != nil {
+		return display.Record{}, err
+	}
+	return display.GroupRecord(group), nil
+}
+
+// handleID returns the groups and projects with the given ID. Groups and
+// projects have separate ID spaces, so ?kind=group or ?kind=project
+// narrows the result to one.
+func (s *Server) handleID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid id %q", r.PathValue("id")))
+		return
+	}
+	kind := r.URL.Query().Get("kind")
+	if kind != "" && kind != "group" && kind != "project" {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid kind %q (want group or project)", kind))
+		return
+	}
+	snap, err := s.snapshot()
+	if err != nil {
+		s.writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	matches := []display.Record{}
+	if g, ok := snap.groups[id]; ok && kind != "project" {
+		matches = append(matches, display.GroupRecord(g))
+	}
+	if p, ok := snap.projects[id]; ok && kind != "group" {
+		matches = append(matches, display.ProjectRecord(p))
+	}
+	s.metrics.cacheLookup(len(matches) > 0)
+	if len(matches) == 0 {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("no cached group or project with ID %d", id))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, matches)
+}
+
+// maxSearchResults caps /search responses unless ?limit= asks for fewer.
+const maxSearchResults = 100
+
+// handleSearch returns cached items whose path or name contains every
+// space-separated word of ?q=, ignoring case.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	terms := strings.Fields(strings.ToLower(r.URL.Query().Get("q")))
+	if len(terms) == 0 {
+		s.writeError(w, http.StatusBadRequest, errors.New("missing q parameter"))
+		return
+	}
+	limit := maxSearchResults
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+			return
+		}
+		limit = min(n, maxSearchResults)
+	}
+	snap, err := s.snapshot()
+	if err != nil {
+		s.writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	results := []display.Record{}
+	for _, rec := range snap.records {
+		text := strings.ToLower(rec.Path + " " + rec.Name)
+		matched := true
+		for _, term := range terms {
+			if !strings.Contains(text, term) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			results = append(results, rec)
+			if len(results) >= limit {
+				break
+			}
+		}
+	}
+	s.writeJSON(w, http.StatusOK, results)
+}
+
+// handleTree returns the cached hierarchy beneath a group, given by full
+// path or numeric ID.
+func (s *Server) handleTree(w http.ResponseWriter, r *http.Request) {
+	ref := strings.Trim(r.PathValue("group"), "/")
+	snap, err := s.snapshot()
+	if err != nil {
+		s.writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	id, err := strconv.Atoi(ref)
+	if err != nil {
+		if rec, ok := snap.byPath[strings.ToLower(ref)]; ok && rec.Kind == "group" {
+			id = rec.ID
+		}
+	}
+	_, ok := snap.groups[id]
+	s.metrics.cacheLookup(ok)
+	if !ok {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("no cached group %q", ref))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, display.NewTreeRecord(snap.tree(id)))
+}
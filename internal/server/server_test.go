@@ -0,0 +1,385 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bboles/glids/internal/display"
+	"github.com/bboles/glids/internal/gitlab"
+	"github.com/bboles/glids/internal/gitlabtest"
+)
+
+// newTestServer returns a Server caching the active groups and projects of
+// a fake GitLab holding:
+//
+//	platform (1)
+//	├── teams (2)
+//	│   └── api (20)
+//	├── web (10)
+//	└── legacy (11, inactive so not cached)
+func newTestServer(t *testing.T) (*Server, *gitlabtest.Server) {
+	t.Helper()
+	fake := gitlabtest.NewServer()
+	t.Cleanup(fake.Close)
+	platform, teams := 1, 2
+	fake.AddGroup(
+		gitlab.Group{ID: platform, Name: "platform", FullPath: "platform"},
+		gitlab.Group{ID: teams, ParentID: &platform, Name: "teams", FullPath: "platform/teams"},
+	)
+	fake.AddProject(
+		gitlab.Project{ID: 10, Name: "web", PathWithNamespace: "platform/web", Namespace: gitlab.Namespace{ID: platform, Kind: "group"}},
+		gitlab.Project{ID: 11, Name: "legacy", PathWithNamespace: "platform/legacy", Namespace: gitlab.Namespace{ID: platform, Kind: "group"},
+			LastActivityAt: time.Now().AddDate(-1, 0, 0)},
+		gitlab.Project{ID: 20, Name: "api", PathWithNamespace: "platform/teams/api", Namespace: gitlab.Namespace{ID: teams, Kind: "group"}},
+	)
+	client := fake.Client()
+	srv := New(client, func() ([]gitlab.Group, []gitlab.Project, error) {
+		groups, err := client.GetGroups("", true)
+		if err != nil {
+			return nil, nil, err
+		}
+		projects, err := client.GetProjects("", false)
+		return groups, projects, err
+	})
+	return srv, fake
+}
+
+// get serves a GET of target and decodes the JSON answer into v, if non-nil.
+func get(t *testing.T, srv *Server, target string, v any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("GET %s: Content-Type %q", target, ct)
+	}
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("GET %s: decoding %q: %v", target, rec.Body, err)
+		}
+	}
+	return rec.Code
+}
+
+// countRequests returns how many requests the fake received for paths
+// starting with prefix.
+func countRequests(fake *gitlabtest.Server, prefix string) int {
+	n := 0
+	for _, r := range fake.Requests() {
+		if strings.HasPrefix(r, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestHealthAndReady(t *testing.T) {
+	srv, _ := newTestServer(t)
+	if code := get(t, srv, "/healthz", nil); code != http.StatusOK {
+		t.Errorf("/healthz before refresh: %d", code)
+	}
+	if code := get(t, srv, "/readyz", nil); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz before refresh: %d, want 503", code)
+	}
+	if code := get(t, srv, "/resolve?path=platform", nil); code != http.StatusServiceUnavailable {
+		t.Errorf("/resolve before refresh: %d, want 503", code)
+	}
+
+	if err := srv.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	var ready struct {
+		Status   string `json:"status"`
+		Groups   int    `json:"groups"`
+		Projects int    `json:"projects"`
+	}
+	if code := get(t, srv, "/readyz", &ready); code != http.StatusOK {
+		t.Fatalf("/readyz after refresh: %d", code)
+	}
+	if ready.Status != "ready" || ready.Groups != 2 || ready.Projects != 2 {
+		t.Errorf("/readyz = %+v, want ready with 2 groups and 2 projects", ready)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	srv, _ := newTestServer(t)
+	if err := srv.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantKind string
+		wantID   int
+	}{
+		{"platform/teams/api", http.StatusOK, "project", 20},
+		{"/Platform/Teams/", http.StatusOK, "group", 2},   // Case and slashes are ignored
+		{"platform/legacy", http.StatusOK, "project", 11}, // Not cached, found on GitLab
+		{"platform/nothing", http.StatusNotFound, "", 0},
+		{"", http.StatusBadRequest, "", 0},
+	}
+	for _, tt := range tests {
+		var rec display.Record
+		code := get(t, srv, "/resolve?path="+tt.path, &rec)
+		if code != tt.wantCode {
+			t.Errorf("/resolve %q: status %d, want %d", tt.path, code, tt.wantCode)
+			continue
+		}
+		if code == http.StatusOK && (rec.Kind != tt.wantKind || rec.ID != tt.wantID) {
+			t.Errorf("/resolve %q = %s %d, want %s %d", tt.path, rec.Kind, rec.ID, tt.wantKind, tt.wantID)
+		}
+	}
+}
+
+func TestResolveCachesNotFound(t *testing.T) {
+	srv, fake := newTestServer(t)
+	if err := srv.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	lookups := func() int {
+		return countRequests(fake, "/api/v4/projects/platform%2Fnew") + countRequests(fake, "/api/v4/groups/platform%2Fnew")
+	}
+	for range 3 {
+		if code := get(t, srv, "/resolve?path=platform/new", nil); code != http.StatusNotFound {
+			t.Fatalf("/resolve before creation: %d, want 404", code)
+		}
+	}
+	if n := lookups(); n != 2 {
+		t.Errorf("GitLab asked %d times for a missing path, want 2 (project then group) for the first request only", n)
+	}
+
+	// The answer is kept until the next refresh, even once the project exists
+	platform := 1
+	fake.AddProject(gitlab.Project{ID: 30, Name: "new", PathWithNamespace: "platform/new", Namespace: gitlab.Namespace{ID: platform, Kind: "group"}})
+	if code := get(t, srv, "/resolve?path=platform/new", nil); code != http.StatusNotFound {
+		t.Errorf("/resolve before refresh: %d, want cached 404", code)
+	}
+	if err := srv.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	var rec display.Record
+	if code := get(t, srv, "/resolve?path=platform/new", &rec); code != http.StatusOK || rec.ID != 30 {
+		t.Errorf("/resolve after refresh: %d %+v, want project 30", code, rec)
+	}
+}
+
+func TestResolveNotFoundLimit(t *testing.T) {
+	srv, fake := newTestServer(t)
+	if err := srv.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	defer func(n int64) { maxNotFound = n }(maxNotFound)
+	maxNotFound = 1
+
+	lookups := func(name string) int {
+		return countRequests(fake, "/api/v4/projects/platform%2F"+name) + countRequests(fake, "/api/v4/groups/platform%2F"+name)
+	}
+	for range 2 {
+		for _, name := range []string{"first", "second"} {
+			if code := get(t, srv, "/resolve?path=platform/"+name, nil); code != http.StatusNotFound {
+				t.Fatalf("/resolve %s: %d, want 404", name, code)
+			}
+		}
+	}
+	if n := lookups("first"); n != 2 {
+		t.Errorf("GitLab asked %d times for the first missing path, want 2 (cached)", n)
+	}
+	if n := lookups("second"); n != 4 {
+		t.Errorf("GitLab asked %d times for the second missing path, want 4 (cache full)", n)
+	}
+}
+
+func TestResolveGitLabError(t *testing.T) {
+	srv, fake := newTestServer(t)
+	if err := srv.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	fake.Fail("/projects/", http.StatusInternalServerError, 1)
+	if code := get(t, srv, "/resolve?path=platform/other", nil); code != http.StatusBadGateway {
+		t.Errorf("/resolve with GitLab failing: %d, want 502", code)
+	}
+	// Errors other than 404 are not cached
+	if code := get(t, srv, "/resolve?path=platform/other", nil); code != http.StatusNotFound {
+		t.Errorf("/resolve after GitLab recovered: %d, want 404", code)
+	}
+}
+
+func TestID(t *testing.T) {
+	srv, _ := newTestServer(t)
+	if err := srv.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target   string
+		wantCode int
+		wantKeys []string
+	}{
+		{"/id/2", http.StatusOK, []string{"group platform/teams"}},
+		{"/id/20", http.StatusOK, []string{"project platform/teams/api"}},
+		{"/id/20?kind=project", http.StatusOK, []string{"project platform/teams/api"}},
+		{"/id/20?kind=group", http.StatusNotFound, nil},
+		{"/id/99", http.StatusNotFound, nil},
+		{"/id/abc", http.StatusBadRequest, nil},
+		{"/id/2?kind=user", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		var recs []display.Record
+		var v any
+		if tt.wantCode == http.StatusOK {
+			v = &recs
+		}
+		code := get(t, srv, tt.target, v)
+		if code != tt.wantCode {
+			t.Errorf("%s: status %d, want %d", tt.target, code, tt.wantCode)
+			continue
+		}
+		var keys []string
+		for _, rec := range recs {
+			keys = append(keys, rec.Kind+" "+rec.Path)
+		}
+		if !slices.Equal(keys, tt.wantKeys) {
+			t.Errorf("%s = %v, want %v", tt.target, keys, tt.wantKeys)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	srv, _ := newTestServer(t)
+	if err := srv.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query     string
+		wantCode  int
+		wantPaths []string
+	}{
+		{"q=teams", http.StatusOK, []string{"platform/teams", "platform/teams/api"}},
+		{"q=TEAMS+api", http.StatusOK, []string{"platform/teams/api"}},
+		{"q=platform&limit=2", http.StatusOK, []string{"platform", "platform/teams"}},
+		{"q=legacy", http.StatusOK, nil}, // Not cached
+		{"q=", http.StatusBadRequest, nil},
+		{"q=api&limit=0", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		var recs []display.Record
+		var v any
+		if tt.wantCode == http.StatusOK {
+			v = &recs
+		}
+		code := get(t, srv, "/search?"+tt.query, v)
+		if code != tt.wantCode {
+			t.Errorf("/search?%s: status %d, want %d", tt.query, code, tt.wantCode)
+			continue
+		}
+		var paths []string
+		for _, rec := range recs {
+			paths = append(paths, rec.Path)
+		}
+		if !slices.Equal(paths, tt.wantPaths) {
+			t.Errorf("/search?%s = %v, want %v", tt.query, paths, tt.wantPaths)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	srv, _ := newTestServer(t)
+	if err := srv.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range []string{"/tree/platform", "/tree/1", "/tree/Platform/"} {
+		var tree display.TreeRecord
+		if code := get(t, srv, target, &tree); code != http.StatusOK {
+			t.Errorf("%s: status %d", target, code)
+			continue
+		}
+		if tree.ID != 1 || len(tree.Subgroups) != 1 || len(tree.Projects) != 1 {
+			t.Errorf("%s = %+v, want platform with teams and web", target, tree)
+			continue
+		}
+		if teams := tree.Subgroups[0]; teams.Path != "platform/teams" || len(teams.Projects) != 1 || teams.Projects[0].ID != 20 {
+			t.Errorf("%s: subgroup %+v, want platform/teams with api", target, teams)
+		}
+	}
+	for _, target := range []string{"/tree/platform/teams/api", "/tree/99"} {
+		if code := get(t, srv, target, nil); code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", target, code)
+		}
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	srv, fake := newTestServer(t)
+	if err := srv.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed refresh keeps serving the previous cache
+	fake.Fail("/projects", http.StatusServiceUnavailable, 1)
+	var apiErr *gitlab.APIError
+	if err := srv.Refresh(); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Refresh with GitLab failing = %v, want a 503 APIError", err)
+	}
+	if code := get(t, srv, "/resolve?path=platform/web", nil); code != http.StatusOK {
+		t.Errorf("/resolve after failed refresh: %d, want cached 200", code)
+	}
+
+	// A successful one picks up new items
+	teams := 2
+	fake.AddProject(gitlab.Project{ID: 21, Name: "worker", PathWithNamespace: "platform/teams/worker", Namespace: gitlab.Namespace{ID: teams, Kind: "group"}})
+	if code := get(t, srv, "/id/21", nil); code != http.StatusNotFound {
+		t.Errorf("/id/21 before refresh: %d, want 404", code)
+	}
+	if err := srv.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	var recs []display.Record
+	get(t, srv, "/id/21", &recs)
+	if len(recs) != 1 || recs[0].Path != "platform/teams/worker" {
+		t.Errorf("/id/21 after refresh = %+v, want platform/teams/worker", recs)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	fake := gitlabtest.NewServer()
+	t.Cleanup(fake.Close)
+	fake.AddGroup(gitlab.Group{ID: 1, Name: "platform", FullPath: "platform"})
+	m := NewMetrics()
+	client := fake.Client(gitlab.WithObserver(m))
+	srv := New(client, func() ([]gitlab.Group, []gitlab.Project, error) {
+		groups, err := client.GetGroups("", true)
+		return groups, nil, err
+	}, WithMetrics(m))
+	if err := srv.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	get(t, srv, "/resolve?path=platform", nil)
+	get(t, srv, "/resolve?path=missing", nil)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`glids_refreshes_total{result="success"} 1`,
+		`glids_inventory_items{kind="group"} 1`,
+		`glids_cache_lookups_total{result="hit"} 1`,
+		`glids_cache_lookups_total{result="miss"} 1`,
+		`glids_gitlab_requests_total{endpoint="/projects/:id",status="404"} 1`,
+		`glids_http_requests_total{route="GET /resolve",status="404"} 1`,
+		"glids_refresh_duration_seconds ",
+		"glids_last_refresh_timestamp_seconds ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics lacks %q:\n%s", want, body)
+		}
+	}
+}
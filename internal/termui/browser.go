@@ -0,0 +1,344 @@
+package termui
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+// browseNode is one group or project in the browser's tree.
+type browseNode struct {
+	group    *gitlab.Group // nil for projects
+	project  gitlab.Project
+	depth    int
+	parent   *browseNode
+	expanded bool
+	loaded   bool // Children have been fetched
+	children []*browseNode
+
+	counted      bool // subgroups/projects hold the pagination totals
+	counting     bool // A background fetch of the counts is under way
+	subgroups    int
+	projects     int
+	countFailure bool
+}
+
+// keyEvent is a key read from the terminal, or the error that ended reading.
+type keyEvent struct {
+	key key
+	err error
+}
+
+// countResult is the outcome of fetching a group row's counts.
+type countResult struct {
+	node                *browseNode
+	subgroups, projects int
+	err                 error
+}
+
+func (n *browseNode) id() int {
+	if n.group != nil {
+		return n.group.ID
+	}
+	return n.project.ID
+}
+
+func (n *browseNode) path() string {
+	if n.group != nil {
+		return n.group.FullPath
+	}
+	return n.project.PathWithNamespace
+}
+
+// Browser is a full-screen tree browser over groups that fetches a group's
+// direct subgroups and projects only when its node is expanded, and caches
+// them. Group rows show their direct subgroup and project counts, read from
+// pagination headers in the background as rows come into view. Right
+// expands, Left collapses, Enter chooses the highlighted node's ID and p its
+// full path.
+type Browser struct {
+	Roots []gitlab.Group
+	// Children fetches the direct subgroups and projects of a group.
+	Children func(gitlab.Group) ([]gitlab.Group, []gitlab.Project, error)
+	// Counts returns the number of direct subgroups and projects of a group.
+	// If nil, no counts are shown.
+	Counts func(gitlab.Group) (subgroups, projects int, err error)
+
+	scr     *screen
+	keys    chan keyEvent    // Keys read by the reader goroutine
+	counts  chan countResult // Counts fetched in the background
+	done    chan struct{}    // Closed when Run returns, to stop both
+	roots   []*browseNode
+	rows    []*browseNode
+	cursor  int
+	offset  int
+	message string
+}
+
+// Run shows the browser until the user chooses a node, returning its ID or
+// full path as text, or leaves with q, Esc or Ctrl+C, which returns ErrAborted.
+func (b *Browser) Run() (string, error) {
+	scr, err := openScreen()
+	if err != nil {
+		return "", err
+	}
+	b.scr = scr
+	b.keys, b.counts, b.done = make(chan keyEvent), make(chan countResult), make(chan struct{})
+	defer func() {
+		close(b.done)
+		scr.close()
+		b.scr = nil
+	}()
+	for i := range b.Roots {
+		b.roots = append(b.roots, &browseNode{group: &b.Roots[i]})
+	}
+	go b.readKeys(scr)
+
+	for {
+		b.flatten()
+		b.render()
+		b.startCounts()
+
+		var k key
+		select {
+		case r := <-b.counts:
+			b.applyCount(r)
+			continue // Redraw with the new counts
+		case ev := <-b.keys:
+			if ev.err != nil {
+				return "", ev.err
+			}
+			k = ev.key
+		}
+		b.message = ""
+		switch {
+		case k.kind == keyEscape || k.kind == keyCancel || (k.kind == keyRune && k.r == 'q'):
+			return "", ErrAborted
+		case k.kind == keyEnter && len(b.rows) > 0:
+			return strconv.Itoa(b.rows[b.cursor].id()), nil
+		case k.kind == keyRune && k.r == 'p' && len(b.rows) > 0:
+			return b.rows[b.cursor].path(), nil
+		case k.kind == keyUp || (k.kind == keyRune && k.r == 'k'):
+			b.cursor--
+		case k.kind == keyDown || (k.kind == keyRune && k.r == 'j'):
+			b.cursor++
+		case k.kind == keyPageUp:
+			b.cursor -= b.pageSize()
+		case k.kind == keyPageDown:
+			b.cursor += b.pageSize()
+		case k.kind == keyRight || (k.kind == keyRune && (k.r == 'l' || k.r == ' ')):
+			b.expand()
+		case k.kind == keyLeft || (k.kind == keyRune && k.r == 'h'):
+			b.collapse()
+		}
+	}
+}
+
+// Confirm asks a yes/no question on the browser's bottom line. It is meant
+// to be installed as the client's confirmation function while the browser
+// runs; outside Run it declines.
+func (b *Browser) Confirm(prompt string) bool {
+	if b.scr == nil {
+		return false
+	}
+	b.message = prompt + " (y/n)"
+	b.render()
+	ev := <-b.keys
+	b.message = ""
+	return ev.err == nil && ev.key.kind == keyRune && (ev.key.r == 'y' || ev.key.r == 'Y')
+}
+
+// readKeys reads keys from the terminal and hands them to Run (or Confirm)
+// until reading fails or Run returns. A read already waiting when Run
+// returns takes one more key before the goroutine notices.
+func (b *Browser) readKeys(scr *screen) {
+	for {
+		k, err := scr.readKey()
+		select {
+		case b.keys <- keyEvent{k, err}:
+		case <-b.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// expand opens the group under the cursor, fetching its children on first use.
+func (b *Browser) expand() {
+	if len(b.rows) == 0 {
+		return
+	}
+	n := b.rows[b.cursor]
+	if n.group == nil || n.expanded {
+		return
+	}
+	if !n.loaded {
+		b.message = fmt.Sprintf("Loading %s...", n.group.FullPath)
+		b.render()
+		subgroups, projects, err := b.Children(*n.group)
+		b.message = ""
+		if err != nil {
+			b.message = fmt.Sprintf("Error loading %s: %v", n.group.FullPath, err)
+			if errors.Is(err, gitlab.ErrCancelled) {
+				return
+			}
+		}
+		n.children = nil
+		for i := range subgroups {
+			n.children = append(n.children, &browseNode{group: &subgroups[i], depth: n.depth + 1, parent: n})
+		}
+		for _, p := range projects {
+			n.children = append(n.children, &browseNode{project: p, depth: n.depth + 1, parent: n})
+		}
+		n.loaded = err == nil
+		if n.loaded {
+			// The fetched lists are the exact counts
+			n.counted, n.subgroups, n.projects = true, len(subgroups), len(projects)
+		}
+	}
+	n.expanded = true
+}
+
+// collapse closes the group under the cursor, or moves to its parent.
+func (b *Browser) collapse() {
+	if len(b.rows) == 0 {
+		return
+	}
+	n := b.rows[b.cursor]
+	if n.expanded {
+		n.expanded = false
+		return
+	}
+	if n.parent != nil {
+		for i, row := range b.rows {
+			if row == n.parent {
+				b.cursor = i
+				return
+			}
+		}
+	}
+}
+
+// flatten lists the visible nodes in display order.
+func (b *Browser) flatten() {
+	b.rows = b.rows[:0]
+	var walk func(nodes []*browseNode)
+	walk = func(nodes []*browseNode) {
+		for _, n := range nodes {
+			b.rows = append(b.rows, n)
+			if n.expanded {
+				walk(n.children)
+			}
+		}
+	}
+	walk(b.roots)
+	b.cursor = max(0, min(b.cursor, len(b.rows)-1))
+}
+
+// pageSize is the number of rows that fit on screen.
+func (b *Browser) pageSize() int {
+	_, height := b.scr.size()
+	return max(1, height-2) // Help line and message line
+}
+
+// visible returns the rows on screen, scrolling to keep the cursor in view.
+func (b *Browser) visible() []*browseNode {
+	rowsShown := b.pageSize()
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	}
+	if b.cursor >= b.offset+rowsShown {
+		b.offset = b.cursor - rowsShown + 1
+	}
+	b.offset = max(0, min(b.offset, len(b.rows)))
+	return b.rows[b.offset:min(len(b.rows), b.offset+rowsShown)]
+}
+
+// render draws the browser.
+func (b *Browser) render() {
+	width, height := b.scr.size()
+	lines := []string{dimVideo + "↑↓ move  → expand  ← collapse  Enter print ID  p print path  q quit" + resetVideo}
+	for i, n := range b.visible() {
+		lines = append(lines, b.formatRow(n, b.offset+i == b.cursor))
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, b.message)
+	b.scr.draw(lines, width, height)
+}
+
+// startCounts fetches, in the background, the counts of the visible group
+// rows that have none yet. They are fetched one after another and each is
+// handed to Run as soon as it is known, so the frame is never held up by
+// the API and rows fill in as their counts arrive.
+func (b *Browser) startCounts() {
+	if b.Counts == nil {
+		return
+	}
+	var pending []*browseNode
+	for _, n := range b.visible() {
+		if n.group != nil && !n.counted && !n.counting && !n.countFailure {
+			n.counting = true
+			pending = append(pending, n)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+	go func() {
+		for _, n := range pending {
+			subgroups, projects, err := b.Counts(*n.group)
+			select {
+			case b.counts <- countResult{n, subgroups, projects, err}:
+			case <-b.done:
+				return
+			}
+		}
+	}()
+}
+
+// applyCount records a group row's counts, unless expanding it has already
+// given the exact ones.
+func (b *Browser) applyCount(r countResult) {
+	n := r.node
+	n.counting = false
+	switch {
+	case r.err != nil:
+		n.countFailure = true
+	case !n.counted:
+		n.counted, n.subgroups, n.projects = true, r.subgroups, r.projects
+	}
+}
+
+// formatRow renders one node, highlighted when selected.
+func (b *Browser) formatRow(n *browseNode, selected bool) string {
+	indent := strings.Repeat("  ", n.depth)
+	var line string
+	if n.group != nil {
+		marker := "▸ "
+		if n.expanded {
+			marker = "▾ "
+		}
+		label := n.group.Name
+		if n.depth == 0 {
+			label = n.group.FullPath // Roots show where they are
+		}
+		counts := ""
+		if n.counted {
+			counts = fmt.Sprintf("  %s(%d subgroups, %d projects)%s", dimVideo, n.subgroups, n.projects, resetVideo)
+		}
+		line = fmt.Sprintf("%s%s[G] %s %s[ID=%d]%s%s", indent, marker, label, dimVideo, n.group.ID, resetVideo, counts)
+	} else {
+		line = fmt.Sprintf("%s  [P] %s %s[ID=%d]%s", indent, n.project.Name, dimVideo, n.project.ID, resetVideo)
+	}
+	if selected {
+		return reverseVideo + "> " + strings.ReplaceAll(line, resetVideo, resetVideo+reverseVideo)
+	}
+	return "  " + line
+}
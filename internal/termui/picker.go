@@ -0,0 +1,324 @@
+package termui
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bboles/glids/internal/display"
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+// ErrAborted is returned when an interactive view is left without choosing anything.
+var ErrAborted = errors.New("no selection made")
+
+// pickView selects which kinds of items the picker lists.
+type pickView int
+
+const (
+	viewAll pickView = iota
+	viewGroups
+	viewProjects
+)
+
+// pickRow is one visible line of the picker.
+type pickRow struct {
+	record display.Record
+	depth  int           // 0 for matches, deeper for expanded children
+	group  *gitlab.Group // Set for group rows
+}
+
+// Picker is a full-screen, keyboard-driven fuzzy finder over groups and
+// projects. Typing filters incrementally; arrows move; Tab switches between
+// all items, groups and projects; Right expands a group in place (populating
+// it through Expand the first time) and Left collapses it; Enter chooses.
+type Picker struct {
+	Groups   []gitlab.Group
+	Projects []gitlab.Project
+	// Expand returns the group with its direct subgroups and projects
+	// populated; subgroups are expanded through it in turn. If nil, groups
+	// cannot be expanded.
+	Expand func(gitlab.Group) (gitlab.Group, error)
+	// Query is the initial filter text.
+	Query string
+
+	scr       *screen
+	view      pickView
+	rows      []pickRow
+	cursor    int
+	offset    int
+	expanded  map[int]bool
+	populated map[int]*gitlab.Group
+	message   string
+}
+
+// Run shows the picker until the user chooses an item, which is returned,
+// or leaves with Esc or Ctrl+C, which returns ErrAborted.
+func (p *Picker) Run() (display.Record, error) {
+	scr, err := openScreen()
+	if err != nil {
+		return display.Record{}, err
+	}
+	p.scr = scr
+	defer func() {
+		scr.close()
+		p.scr = nil
+	}()
+	p.expanded = make(map[int]bool)
+	p.populated = make(map[int]*gitlab.Group)
+
+	for {
+		p.rebuild()
+		p.render()
+
+		k, err := scr.readKey()
+		if err != nil {
+			return display.Record{}, err
+		}
+		p.message = ""
+		switch k.kind {
+		case keyEnter:
+			if len(p.rows) > 0 {
+				return p.rows[p.cursor].record, nil
+			}
+		case keyEscape, keyCancel:
+			return display.Record{}, ErrAborted
+		case keyRune:
+			p.Query += string(k.r)
+			p.cursor = 0
+		case keyBackspace:
+			if runes := []rune(p.Query); len(runes) > 0 {
+				p.Query = string(runes[:len(runes)-1])
+				p.cursor = 0
+			}
+		case keyClear:
+			p.Query = ""
+			p.cursor = 0
+		case keyTab:
+			p.view = (p.view + 1) % 3
+			p.cursor = 0
+		case keyUp:
+			p.cursor--
+		case keyDown:
+			p.cursor++
+		case keyPageUp:
+			p.cursor -= p.pageSize()
+		case keyPageDown:
+			p.cursor += p.pageSize()
+		case keyRight:
+			p.expand()
+		case keyLeft:
+			p.collapse()
+		}
+	}
+}
+
+// Confirm asks a yes/no question on the picker's bottom line. It is meant
+// to be installed as the client's confirmation function while the picker
+// runs; outside Run it declines.
+func (p *Picker) Confirm(prompt string) bool {
+	if p.scr == nil {
+		return false
+	}
+	p.message = prompt + " (y/n)"
+	p.render()
+	k, err := p.scr.readKey()
+	p.message = ""
+	return err == nil && k.kind == keyRune && (k.r == 'y' || k.r == 'Y')
+}
+
+// expand opens the group under the cursor, populating it on first use.
+func (p *Picker) expand() {
+	if len(p.rows) == 0 || p.Expand == nil {
+		return
+	}
+	row := p.rows[p.cursor]
+	if row.group == nil || p.expanded[row.group.ID] {
+		return
+	}
+	if p.populated[row.group.ID] == nil {
+		p.message = fmt.Sprintf("Loading %s...", row.group.FullPath)
+		p.render()
+		g, err := p.Expand(*row.group)
+		if err != nil {
+			p.message = fmt.Sprintf("Error expanding %s: %v", row.group.FullPath, err)
+			if errors.Is(err, gitlab.ErrCancelled) {
+				return
+			}
+		}
+		p.populated[row.group.ID] = &g
+	}
+	p.expanded[row.group.ID] = true
+}
+
+// collapse closes the group under the cursor, or moves to the parent row.
+func (p *Picker) collapse() {
+	if len(p.rows) == 0 {
+		return
+	}
+	row := p.rows[p.cursor]
+	if row.group != nil && p.expanded[row.group.ID] {
+		delete(p.expanded, row.group.ID)
+		return
+	}
+	for i := p.cursor - 1; i >= 0 && row.depth > 0; i-- {
+		if p.rows[i].depth == row.depth-1 {
+			p.cursor = i
+			return
+		}
+	}
+}
+
+// rebuild recomputes the visible rows from the query, view and expansions.
+func (p *Picker) rebuild() {
+	type match struct {
+		row   pickRow
+		score int
+	}
+	var matches []match
+	if p.view != viewProjects {
+		for i := range p.Groups {
+			g := &p.Groups[i]
+			if score, ok := fuzzyScore(p.Query, g.FullPath); ok {
+				matches = append(matches, match{pickRow{record: display.GroupRecord(*g), group: g}, score})
+			}
+		}
+	}
+	if p.view != viewGroups {
+		for _, proj := range p.Projects {
+			if score, ok := fuzzyScore(p.Query, proj.PathWithNamespace); ok {
+				matches = append(matches, match{pickRow{record: display.ProjectRecord(proj)}, score})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return strings.ToLower(matches[i].row.record.Path) < strings.ToLower(matches[j].row.record.Path)
+	})
+
+	p.rows = p.rows[:0]
+	for _, m := range matches {
+		p.rows = append(p.rows, m.row)
+		if m.row.group != nil && p.expanded[m.row.group.ID] {
+			if tree := p.populated[m.row.group.ID]; tree != nil {
+				p.appendChildren(tree, 1)
+			}
+		}
+	}
+
+	p.cursor = max(0, min(p.cursor, len(p.rows)-1))
+}
+
+// appendChildren adds rows for the children of a populated group, recursing
+// into expanded subgroups.
+func (p *Picker) appendChildren(g *gitlab.Group, depth int) {
+	for i := range g.Subgroups {
+		sub := &g.Subgroups[i]
+		if p.view != viewProjects {
+			p.rows = append(p.rows, pickRow{record: display.GroupRecord(*sub), depth: depth, group: sub})
+		}
+		if tree := p.populated[sub.ID]; tree != nil && p.expanded[sub.ID] {
+			p.appendChildren(tree, depth+1)
+		}
+	}
+	if p.view == viewGroups {
+		return
+	}
+	for _, proj := range g.Projects {
+		p.rows = append(p.rows, pickRow{record: display.ProjectRecord(proj), depth: depth})
+	}
+}
+
+// pageSize is the number of rows that fit on screen.
+func (p *Picker) pageSize() int {
+	_, height := p.scr.size()
+	return max(1, height-3) // Query line, help line and message line
+}
+
+// render draws the picker.
+func (p *Picker) render() {
+	width, height := p.scr.size()
+	rowsShown := p.pageSize()
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+rowsShown {
+		p.offset = p.cursor - rowsShown + 1
+	}
+
+	views := []string{"All", "Groups", "Projects"}
+	for i := range views {
+		if pickView(i) == p.view {
+			views[i] = "[" + views[i] + "]"
+		}
+	}
+	lines := []string{
+		"> " + p.Query + "_",
+		fmt.Sprintf("%s  %d items  %s  ↑↓ move  →← expand/collapse  Tab switch  Enter select  Esc quit%s",
+			dimVideo, len(p.rows), strings.Join(views, " "), resetVideo),
+	}
+	for i := p.offset; i < len(p.rows) && i < p.offset+rowsShown; i++ {
+		lines = append(lines, p.formatRow(p.rows[i], i == p.cursor))
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, p.message)
+	p.scr.draw(lines, width, height)
+}
+
+// formatRow renders one row, highlighted when selected.
+func (p *Picker) formatRow(row pickRow, selected bool) string {
+	marker := "  "
+	kind := "[P]"
+	if row.group != nil {
+		kind = "[G]"
+		marker = "▸ "
+		if p.expanded[row.group.ID] {
+			marker = "▾ "
+		}
+	}
+	label := row.record.Path
+	if row.depth > 0 {
+		label = row.record.Name // Children show names, as in the hierarchy view
+	}
+	line := fmt.Sprintf("%s%s%s %s %s[ID=%d]%s", strings.Repeat("  ", row.depth), marker, kind, label, dimVideo, row.record.ID, resetVideo)
+	if selected {
+		return reverseVideo + "> " + strings.ReplaceAll(line, resetVideo, resetVideo+reverseVideo)
+	}
+	return "  " + line
+}
+
+// fuzzyScore reports whether every character of query appears in text in
+// order (case-insensitively) and scores the match: contiguous substrings
+// beat scattered characters, and earlier matches beat later ones.
+func fuzzyScore(query, text string) (int, bool) {
+	if query == "" {
+		return 0, true
+	}
+	q := []rune(strings.ToLower(query))
+	t := []rune(strings.ToLower(text))
+	if idx := strings.Index(string(t), string(q)); idx >= 0 {
+		return 1000 - len([]rune(string(t)[:idx])), true
+	}
+	qi, prev, gaps := 0, -1, 0
+	for ti, r := range t {
+		if qi == len(q) {
+			break
+		}
+		if r == q[qi] {
+			if prev >= 0 {
+				gaps += ti - prev - 1
+			}
+			prev = ti
+			qi++
+		}
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return 500 - gaps, true
+}
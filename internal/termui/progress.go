@@ -0,0 +1,121 @@
+package termui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+const progressBarWidth = 20 // Number of cells in the determinate progress bar
+
+// listingKey identifies one paginated listing, e.g. the projects of group 12.
+type listingKey struct {
+	resource string
+	groupID  int
+}
+
+// progress accumulates PageReceived events into totals for display.
+// Top-level listings (groups, projects) each get a fresh count; per-group
+// listings made while populating a hierarchy are aggregated, since their
+// number is only known once the whole tree has been walked.
+type progress struct {
+	start      time.Time
+	pages      int
+	items      int
+	totalPages int  // Sum of X-Total-Pages over listings seen so far
+	totalItems int  // Sum of X-Total over listings seen so far
+	unknown    bool // Some listing came without totals (GitLab omits them above 10,000 items)
+	aggregate  bool // Counting per-group listings of a hierarchy
+	groups     int  // Groups populated so far
+	seen       map[listingKey]bool
+}
+
+// reset clears all counters and restarts the elapsed time.
+func (p *progress) reset(now time.Time) {
+	*p = progress{start: now, seen: make(map[listingKey]bool)}
+}
+
+// handle updates the counters for one client event.
+func (p *progress) handle(e gitlab.Event, now time.Time) {
+	if p.seen == nil {
+		p.reset(now)
+	}
+	key := listingKey{resource: e.Resource, groupID: e.GroupID}
+	switch e.Kind {
+	case gitlab.FetchStarted:
+		if e.GroupID == 0 && !p.aggregate {
+			p.reset(now) // A new flat listing gets its own bar
+		} else {
+			p.aggregate = true
+		}
+		delete(p.seen, key)
+	case gitlab.PageReceived:
+		if !p.seen[key] {
+			// First page of this listing: add its totals
+			p.seen[key] = true
+			if e.Page.TotalPages > 0 {
+				p.totalPages += e.Page.TotalPages
+				p.totalItems += e.Page.Total
+			} else {
+				p.unknown = true
+			}
+		}
+		p.pages++
+		p.items += e.Items
+	case gitlab.GroupPopulated:
+		p.groups++
+	}
+}
+
+// determinate reports whether totals are known for every listing seen.
+func (p *progress) determinate() bool {
+	return !p.unknown && p.totalPages > 0
+}
+
+// format renders the progress counters, or "" if nothing has been received yet.
+func (p *progress) format(now time.Time) string {
+	if p.pages == 0 && p.groups == 0 {
+		return ""
+	}
+	elapsed := now.Sub(p.start)
+	var parts []string
+
+	if p.determinate() {
+		pages := min(p.pages, p.totalPages)
+		filled := progressBarWidth * pages / p.totalPages
+		bar := "[" + strings.Repeat("#", filled) + strings.Repeat(".", progressBarWidth-filled) + "]"
+		parts = append(parts,
+			fmt.Sprintf("%s %d/%d pages", bar, pages, p.totalPages),
+			fmt.Sprintf("%d/%d items", p.items, p.totalItems))
+	} else {
+		parts = append(parts,
+			fmt.Sprintf("%d pages", p.pages),
+			fmt.Sprintf("%d items", p.items))
+	}
+	if p.aggregate {
+		parts = append(parts, fmt.Sprintf("%d groups", p.groups))
+	}
+	parts = append(parts, formatDuration(elapsed)+" elapsed")
+
+	// The remaining work of a hierarchy is unknown until it has been walked,
+	// so only flat listings get an estimate.
+	if p.determinate() && !p.aggregate && p.pages > 0 && p.pages < p.totalPages {
+		eta := elapsed * time.Duration(p.totalPages-p.pages) / time.Duration(p.pages)
+		parts = append(parts, "ETA "+formatDuration(eta))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// formatDuration renders d as m:ss, or h:mm:ss for long runs.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	m := int(d/time.Minute) % 60
+	s := int(d/time.Second) % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
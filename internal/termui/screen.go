@@ -0,0 +1,193 @@
+package termui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// ANSI sequences used by the full-screen views.
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[?25l" // Alternate screen buffer, hidden cursor
+	leaveAltScreen = "\x1b[?25h\x1b[?1049l" // Visible cursor, main screen buffer
+	cursorHome     = "\x1b[H"
+	clearToEnd     = "\x1b[K"
+	reverseVideo   = "\x1b[7m"
+	dimVideo       = "\x1b[2m"
+	resetVideo     = "\x1b[0m"
+)
+
+// keyKind identifies a decoded key press.
+type keyKind int
+
+const (
+	keyRune keyKind = iota
+	keyEnter
+	keyEscape
+	keyCancel // Ctrl+C
+	keyBackspace
+	keyClear // Ctrl+U
+	keyTab
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPageUp
+	keyPageDown
+	keyUnknown
+)
+
+// key is a single decoded key press; r is set for keyRune.
+type key struct {
+	kind keyKind
+	r    rune
+}
+
+// screen is a full-screen view drawn on stderr with keys read from stdin in
+// raw mode. Stdout is left untouched so a selection can be printed after the
+// screen is closed, e.g. inside $(glids -i).
+type screen struct {
+	in       *os.File
+	out      *os.File
+	oldState *term.State
+	pending  []byte // Bytes read but not yet decoded
+}
+
+// openScreen switches the terminal to raw mode and the alternate screen.
+// Both stdin and stderr must be terminals.
+func openScreen() (*screen, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil, fmt.Errorf("interactive mode needs a terminal on stdin and stderr")
+	}
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("error setting raw mode: %w", err)
+	}
+	s := &screen{in: os.Stdin, out: os.Stderr, oldState: oldState}
+	fmt.Fprint(s.out, enterAltScreen)
+	return s, nil
+}
+
+// close restores the main screen and the terminal state.
+func (s *screen) close() {
+	fmt.Fprint(s.out, leaveAltScreen)
+	term.Restore(int(s.in.Fd()), s.oldState)
+}
+
+// size returns the terminal width and height, defaulting to 80x24.
+func (s *screen) size() (int, int) {
+	width, height, err := term.GetSize(int(s.out.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// draw replaces the screen contents with lines, clipping each to width.
+// In raw mode lines must end in CR LF.
+func (s *screen) draw(lines []string, width, height int) {
+	var b strings.Builder
+	b.WriteString(cursorHome)
+	for i := 0; i < height; i++ {
+		if i < len(lines) {
+			b.WriteString(clip(lines[i], width))
+		}
+		b.WriteString(resetVideo + clearToEnd)
+		if i < height-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	fmt.Fprint(s.out, b.String())
+}
+
+// readKey blocks until a key has been pressed and decodes it.
+func (s *screen) readKey() (key, error) {
+	if len(s.pending) == 0 {
+		buf := make([]byte, 64)
+		n, err := s.in.Read(buf)
+		if err != nil {
+			return key{}, err
+		}
+		s.pending = buf[:n]
+	}
+	k, size := decodeKey(s.pending)
+	s.pending = s.pending[size:]
+	return k, nil
+}
+
+// escapeKeys maps the escape sequences we understand to keys.
+var escapeKeys = map[string]keyKind{
+	"\x1b[A": keyUp, "\x1bOA": keyUp,
+	"\x1b[B": keyDown, "\x1bOB": keyDown,
+	"\x1b[C": keyRight, "\x1bOC": keyRight,
+	"\x1b[D": keyLeft, "\x1bOD": keyLeft,
+	"\x1b[5~": keyPageUp,
+	"\x1b[6~": keyPageDown,
+}
+
+// decodeKey decodes the first key in b and returns it with the number of
+// bytes it used. A lone ESC (nothing else read with it) is the Escape key.
+func decodeKey(b []byte) (key, int) {
+	switch b[0] {
+	case '\r', '\n':
+		return key{kind: keyEnter}, 1
+	case 3:
+		return key{kind: keyCancel}, 1
+	case 127, 8:
+		return key{kind: keyBackspace}, 1
+	case 21:
+		return key{kind: keyClear}, 1
+	case '\t':
+		return key{kind: keyTab}, 1
+	case 14: // Ctrl+N
+		return key{kind: keyDown}, 1
+	case 16: // Ctrl+P
+		return key{kind: keyUp}, 1
+	case 0x1b:
+		if len(b) == 1 {
+			return key{kind: keyEscape}, 1
+		}
+		for seq, kind := range escapeKeys {
+			if bytes.HasPrefix(b, []byte(seq)) {
+				return key{kind: kind}, len(seq)
+			}
+		}
+		return key{kind: keyUnknown}, len(b) // Drop sequences we don't know
+	}
+	r, size := utf8.DecodeRune(b)
+	if r < 0x20 {
+		return key{kind: keyUnknown}, size
+	}
+	return key{kind: keyRune, r: r}, size
+}
+
+// clip cuts s to at most width visible characters, ignoring ANSI sequences
+// when counting.
+func clip(s string, width int) string {
+	var b strings.Builder
+	visible := 0
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case inEscape:
+			b.WriteRune(r)
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+				inEscape = false
+			}
+		case r == 0x1b:
+			inEscape = true
+			b.WriteRune(r)
+		default:
+			if visible >= width {
+				continue
+			}
+			visible++
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
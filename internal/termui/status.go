@@ -0,0 +1,210 @@
+// Package termui renders glids progress and prompts on a terminal.
+// It subscribes to gitlab.Client events so the client itself never touches
+// the terminal.
+package termui
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bboles/glids/internal/gitlab"
+	"golang.org/x/term"
+)
+
+// clearLine is the ANSI sequence for carriage return and clear to end of line.
+const clearLine = "\r\x1b[K"
+
+// Status is a single-line status display on stderr. On a terminal it
+// animates a spinner next to the current message, followed by a progress bar
+// built from the pagination headers of the pages received so far; otherwise
+// each message is printed once on its own line. Status implements
+// gitlab.Observer and steps aside while the client asks for confirmation.
+type Status struct {
+	out        *os.File
+	isTerminal bool
+	width      int
+
+	mu      sync.Mutex
+	running bool
+	paused  bool
+	message string
+	frame   int
+	prog    progress
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewStatus creates a status display writing to out (normally os.Stderr).
+// Nothing is shown until Start is called.
+func NewStatus(out *os.File) *Status {
+	s := &Status{
+		out:        out,
+		isTerminal: term.IsTerminal(int(out.Fd())),
+		width:      80, // Default width if not a terminal or size check fails
+	}
+	if s.isTerminal {
+		if width, _, err := term.GetSize(int(out.Fd())); err == nil {
+			s.width = width
+		}
+	}
+	return s
+}
+
+// IsTerminal reports whether the status is being drawn on a terminal.
+func (s *Status) IsTerminal() bool {
+	return s.isTerminal
+}
+
+// Start shows message and, on a terminal, begins animating it.
+// Calling Start on a running Status just changes the message.
+func (s *Status) Start(message string) {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		s.SetMessage(message)
+		return
+	}
+	s.running = true
+	s.paused = false
+	s.message = message
+	s.prog.reset(time.Now())
+	if !s.isTerminal {
+		// Not a terminal: just print the message once
+		fmt.Fprintln(s.out, message+"...")
+		s.mu.Unlock()
+		return
+	}
+	s.done = make(chan struct{})
+	s.stopped = make(chan struct{})
+	s.mu.Unlock()
+
+	go s.animate()
+}
+
+// SetMessage replaces the message shown next to the spinner.
+// It has no effect unless the status has been started.
+func (s *Status) SetMessage(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return
+	}
+	s.message = message
+	if !s.isTerminal {
+		fmt.Fprintln(s.out, message)
+		return
+	}
+	if !s.paused {
+		s.drawLocked()
+	}
+}
+
+// Stop halts the animation and clears the status line. It is safe to call
+// more than once.
+func (s *Status) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	if !s.isTerminal {
+		s.mu.Unlock()
+		return
+	}
+	close(s.done)
+	stopped := s.stopped
+	s.mu.Unlock()
+
+	<-stopped // Wait for the animation goroutine to clear the line
+}
+
+// Println prints a line above the status line, e.g. a warning, and then
+// redraws the status.
+func (s *Status) Println(a ...any) {
+	s.Above(func() { fmt.Fprintln(s.out, a...) })
+}
+
+// Above runs fn, which may print to stdout or stderr, with the status line
+// cleared, and redraws the status afterwards so the output appears above it.
+func (s *Status) Above(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running && s.isTerminal {
+		fmt.Fprint(s.out, clearLine)
+	}
+	fn()
+	if s.running && s.isTerminal && !s.paused {
+		s.drawLocked()
+	}
+}
+
+// HandleEvent implements gitlab.Observer. Pages received update the progress
+// bar. The status line is cleared while a confirmation prompt is on screen
+// and restored once it has been answered.
+func (s *Status) HandleEvent(e gitlab.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running || !s.isTerminal {
+		return
+	}
+	s.prog.handle(e, time.Now())
+	switch e.Kind {
+	case gitlab.ConfirmationNeeded:
+		s.paused = true
+		fmt.Fprint(s.out, clearLine)
+	case gitlab.ConfirmationAnswered:
+		s.paused = false
+		if e.Confirmed {
+			s.drawLocked()
+		}
+	}
+}
+
+// animate redraws the status line until Stop is called.
+func (s *Status) animate() {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	defer close(s.stopped)
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if !s.paused {
+				s.frame++
+				s.drawLocked()
+			}
+			s.mu.Unlock()
+		case <-s.done:
+			s.mu.Lock()
+			fmt.Fprint(s.out, clearLine)
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+// drawLocked writes the current status line. s.mu must be held.
+func (s *Status) drawLocked() {
+	progressChars := []string{"|", "/", "-", "\\"}
+	line := fmt.Sprintf("%s %s", s.message, progressChars[s.frame%len(progressChars)])
+	if counts := s.prog.format(time.Now()); counts != "" {
+		line += " " + counts
+	}
+	fmt.Fprint(s.out, clearLine+truncate(line, s.width-1))
+}
+
+// truncate shortens line to at most maxLen characters, ending in "..." when cut.
+func truncate(line string, maxLen int) string {
+	runes := []rune(line)
+	if maxLen <= 0 || len(runes) <= maxLen {
+		return line
+	}
+	if maxLen > 3 {
+		return string(runes[:maxLen-3]) + "..."
+	}
+	return string(runes[:maxLen]) // Very narrow terminal
+}
@@ -0,0 +1,164 @@
+package glids_test
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/bboles/glids/internal/gitlabtest"
+	"github.com/bboles/glids/pkg/glids"
+)
+
+// fakeGitLab starts a fake GitLab holding platform/api, platform/web and
+// platform/tools/cli, so the examples run without network access.
+func fakeGitLab() *gitlabtest.Server {
+	platform, tools := 1, 2
+	srv := gitlabtest.NewServer()
+	srv.AddGroup(
+		glids.Group{ID: platform, Name: "platform", FullPath: "platform"},
+		glids.Group{ID: tools, ParentID: &platform, Name: "tools", FullPath: "platform/tools"},
+	)
+	srv.AddProject(
+		glids.Project{ID: 10, Name: "api", PathWithNamespace: "platform/api", Namespace: glids.Namespace{ID: platform, Kind: "group"}},
+		glids.Project{ID: 11, Name: "web", PathWithNamespace: "platform/web", Namespace: glids.Namespace{ID: platform, Kind: "group"}},
+		glids.Project{ID: 20, Name: "cli", PathWithNamespace: "platform/tools/cli", Namespace: glids.Namespace{ID: tools, Kind: "group"}},
+	)
+	return srv
+}
+
+func ExampleNewClient() {
+	srv := fakeGitLab()
+	defer srv.Close()
+
+	client := glids.NewClient(srv.URL, gitlabtest.Token)
+	projects, err := client.GetProjects("platform/api", true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, p := range projects {
+		fmt.Println(p.PathWithNamespace, p.ID)
+	}
+	// Output: platform/api 10
+}
+
+func ExampleWithConfirmFunc() {
+	srv := fakeGitLab()
+	defer srv.Close()
+	for id := 100; id < 200; id++ { // Enough to need confirmation
+		srv.AddProject(glids.Project{ID: id, PathWithNamespace: fmt.Sprintf("platform/svc%d", id)})
+	}
+
+	client := glids.NewClient(srv.URL, gitlabtest.Token,
+		glids.WithConfirmFunc(func(prompt string) bool {
+			return false // Refuse anything above the threshold
+		}),
+	)
+	_, err := client.GetProjects("", true)
+	fmt.Println(errors.Is(err, glids.ErrCancelled))
+	// Output: true
+}
+
+func ExampleWithObserver() {
+	srv := fakeGitLab()
+	defer srv.Close()
+
+	client := glids.NewClient(srv.URL, gitlabtest.Token,
+		glids.WithObserver(glids.ObserverFunc(func(e glids.Event) {
+			if e.Kind == glids.PageReceived {
+				fmt.Printf("%s: page %d, %d items\n", e.Resource, e.Page.CurrentPage, e.Items)
+			}
+		})),
+	)
+	if _, err := client.GetGroups("", true); err != nil {
+		log.Fatal(err)
+	}
+	// Output: groups: page 1, 2 items
+}
+
+func ExampleClient_Projects() {
+	srv := fakeGitLab()
+	defer srv.Close()
+
+	client := glids.NewClient(srv.URL, gitlabtest.Token)
+	n := 0
+	for project, err := range client.Projects("platform", true) {
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(project.PathWithNamespace)
+		if n++; n == 2 {
+			break // No further pages are fetched
+		}
+	}
+	// Output:
+	// platform/api
+	// platform/web
+}
+
+func ExampleFprintHierarchy() {
+	srv := fakeGitLab()
+	defer srv.Close()
+
+	client := glids.NewClient(srv.URL, gitlabtest.Token)
+	groups, err := client.GetGroups("platform", true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	root := groups[0]
+	if err := client.PopulateGroupHierarchy(&root, true); err != nil {
+		log.Fatal(err)
+	}
+	glids.SortHierarchy(&root, glids.SortName, false)
+	if err := glids.FprintHierarchy(os.Stdout, root, glids.WithASCII(true)); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// platform (ID: 1)
+	//   |-->  tools [G] [ID=2]
+	//   |     `-->  cli [P] [ID=20]
+	//   |-->  api [P] [ID=10]
+	//   `-->  web [P] [ID=11]
+}
+
+func ExampleNewEncoder() {
+	srv := fakeGitLab()
+	defer srv.Close()
+
+	client := glids.NewClient(srv.URL, gitlabtest.Token)
+	projects, err := client.GetProjects("platform", true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	enc, err := glids.NewEncoder(os.Stdout, glids.FormatCSV)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, p := range projects {
+		enc.Project(p)
+	}
+	if err := enc.Close(); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// kind,id,path,name,parent_id
+	// project,10,platform/api,api,1
+	// project,11,platform/web,web,1
+	// project,20,platform/tools/cli,cli,2
+}
+
+func ExampleSortProjects() {
+	projects := []glids.Project{
+		{ID: 3, PathWithNamespace: "platform/web"},
+		{ID: 1, PathWithNamespace: "platform/api"},
+		{ID: 2, PathWithNamespace: "platform/tools/cli"},
+	}
+	glids.SortProjects(projects, glids.SortID, true)
+	for _, p := range projects {
+		fmt.Println(p.ID, p.PathWithNamespace)
+	}
+	// Output:
+	// 3 platform/web
+	// 2 platform/tools/cli
+	// 1 platform/api
+}
@@ -0,0 +1,127 @@
+// Package glids is the public API for looking up GitLab group and project IDs.
+//
+// It exposes the same client, types and formatters the glids command uses,
+// without any dependence on terminal state: confirmation prompts are supplied
+// by the caller through options, and progress is reported as events to any
+// number of observers.
+//
+// See the examples for lookups, confirmation, progress events, streaming and
+// printing hierarchies.
+package glids
+
+import (
+	"iter"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/bboles/glids/internal/gitlab"
+)
+
+// Client handles communication with the GitLab API.
+type Client = gitlab.Client
+
+// Project represents a GitLab project.
+type Project = gitlab.Project
+
+// Group represents a GitLab group or subgroup.
+type Group = gitlab.Group
+
+// ProjectStatistics holds a project's storage use, requested when sorting
+// by size.
+type ProjectStatistics = gitlab.ProjectStatistics
+
+// Namespace is the group or user namespace a project lives in.
+type Namespace = gitlab.Namespace
+
+// PaginationInfo holds information about the total resources and pagination.
+type PaginationInfo = gitlab.PaginationInfo
+
+// Option configures a Client created by NewClient.
+type Option = gitlab.Option
+
+// Event describes progress made by a Client, such as a page being received
+// or a confirmation being needed.
+type Event = gitlab.Event
+
+// EventKind identifies what happened in an Event.
+type EventKind = gitlab.EventKind
+
+// Observer receives events from a Client as they happen.
+type Observer = gitlab.Observer
+
+// ObserverFunc adapts an ordinary function to the Observer interface.
+type ObserverFunc = gitlab.ObserverFunc
+
+// Event kinds emitted by a Client.
+const (
+	FetchStarted         = gitlab.FetchStarted
+	PageReceived         = gitlab.PageReceived
+	ConfirmationNeeded   = gitlab.ConfirmationNeeded
+	ConfirmationAnswered = gitlab.ConfirmationAnswered
+	GroupPopulated       = gitlab.GroupPopulated
+	RequestCompleted     = gitlab.RequestCompleted
+	RequestRetried       = gitlab.RequestRetried
+	RateLimited          = gitlab.RateLimited
+)
+
+// ErrCancelled is returned when the confirmation function declines a large fetch.
+var ErrCancelled = gitlab.ErrCancelled
+
+// APIError is returned when GitLab answers a request with a non-200 status.
+type APIError = gitlab.APIError
+
+// IsNotFound reports whether err is a 404 answer from GitLab.
+func IsNotFound(err error) bool {
+	return gitlab.IsNotFound(err)
+}
+
+// NewClient creates a new GitLab API client for the given base URL
+// (e.g. https://gitlab.example.com) and personal access token. Failed
+// requests are not retried unless WithRetries is given.
+func NewClient(baseURL, token string, opts ...Option) *Client {
+	return gitlab.NewClient(baseURL, token, opts...)
+}
+
+// WithLogger sets the logger used for debug output. By default nothing is logged.
+func WithLogger(logger *log.Logger) Option {
+	return gitlab.WithLogger(logger)
+}
+
+// WithHTTPClient sets the HTTP client used for API requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return gitlab.WithHTTPClient(httpClient)
+}
+
+// WithConfirmFunc sets the function asked before large fetches. It receives a
+// prompt and returns true to proceed. Without one, large fetches proceed.
+func WithConfirmFunc(fn func(string) bool) Option {
+	return gitlab.WithConfirmFunc(fn)
+}
+
+// WithRetries sets how many times a request is retried after a transport
+// error, a 5xx answer or a 429 rate limit. Zero, the default, disables
+// retries.
+func WithRetries(n int) Option {
+	return gitlab.WithRetries(n)
+}
+
+// WithObserver registers an observer for client events. It may be given
+// more than once to subscribe several observers.
+func WithObserver(o Observer) Option {
+	return gitlab.WithObserver(o)
+}
+
+// Paginate returns an iterator over every item of a GitLab listing endpoint,
+// fetching one page at a time as the caller consumes items. path is relative
+// to /api/v4 (e.g. "/groups/12/projects"); set pagination=keyset in query for
+// endpoints that support keyset pagination.
+func Paginate[T any](c *Client, path string, query url.Values) iter.Seq2[T, error] {
+	return gitlab.Paginate[T](c, path, query)
+}
+
+// Collect drains seq into a slice, stopping after limit items when limit is
+// positive. It returns the first error yielded.
+func Collect[T any](seq iter.Seq2[T, error], limit int) ([]T, error) {
+	return gitlab.Collect(seq, limit)
+}
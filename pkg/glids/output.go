@@ -0,0 +1,193 @@
+package glids
+
+import (
+	"io"
+
+	"github.com/bboles/glids/internal/display"
+)
+
+// PrintOption configures the list and tree printers.
+type PrintOption = display.PrintOption
+
+// WithWidth sets the minimum width of the path column in lists; 0 auto-sizes.
+func WithWidth(width int) PrintOption {
+	return display.WithWidth(width)
+}
+
+// WithStats annotates each group in a tree with its statistics and ends the
+// tree with a summary for the root.
+func WithStats(enabled bool) PrintOption {
+	return display.WithStats(enabled)
+}
+
+// WithColor styles groups, projects, IDs and archived or private items
+// with ANSI escape sequences.
+func WithColor(enabled bool) PrintOption {
+	return display.WithColor(enabled)
+}
+
+// WithASCII draws trees with plain ASCII connectors.
+func WithASCII(enabled bool) PrintOption {
+	return display.WithASCII(enabled)
+}
+
+// WithHighlight highlights matches of term in paths and names when colour
+// is enabled.
+func WithHighlight(term string) PrintOption {
+	return display.WithHighlight(term)
+}
+
+// FprintProjectList writes projects to w as aligned "path: id" lines.
+func FprintProjectList(w io.Writer, projects []Project, opts ...PrintOption) error {
+	return display.FprintProjectList(w, projects, opts...)
+}
+
+// FprintGroupList writes groups to w as aligned "path: id" lines.
+func FprintGroupList(w io.Writer, groups []Group, opts ...PrintOption) error {
+	return display.FprintGroupList(w, groups, opts...)
+}
+
+// FprintLists writes groups and projects to w under "Groups:" and
+// "Projects:" headings, with their ID columns aligned.
+func FprintLists(w io.Writer, groups []Group, projects []Project, opts ...PrintOption) error {
+	return display.FprintLists(w, groups, projects, opts...)
+}
+
+// FprintHierarchy writes a populated group and its descendants to w as a tree.
+func FprintHierarchy(w io.Writer, rootGroup Group, opts ...PrintOption) error {
+	return display.FprintHierarchy(w, rootGroup, opts...)
+}
+
+// PrintProjectList prints projects as aligned "path: id" lines on stdout.
+// nameWidth is the minimum width of the path column; 0 auto-sizes.
+func PrintProjectList(projects []Project, nameWidth int) {
+	display.PrintProjectList(projects, nameWidth)
+}
+
+// PrintGroupList prints groups as aligned "path: id" lines on stdout.
+// nameWidth is the minimum width of the path column; 0 auto-sizes.
+func PrintGroupList(groups []Group, nameWidth int) {
+	display.PrintGroupList(groups, nameWidth)
+}
+
+// PrintHierarchy prints a populated group and its descendants as a tree on stdout.
+func PrintHierarchy(rootGroup Group) {
+	display.PrintHierarchy(rootGroup)
+}
+
+// FprintAncestors writes the chain of groups from root down to the target
+// item to w in the hierarchy layout, marking the target.
+func FprintAncestors(w io.Writer, root Group, target Record, opts ...PrintOption) error {
+	return display.FprintAncestors(w, root, target, opts...)
+}
+
+// Format names an output format for NewEncoder.
+type Format = display.Format
+
+// Output formats. FormatDOT and FormatMermaid can only encode hierarchies.
+const (
+	FormatText      = display.FormatText
+	FormatJSON      = display.FormatJSON
+	FormatJSONL     = display.FormatJSONL
+	FormatCSV       = display.FormatCSV
+	FormatMarkdown  = display.FormatMarkdown
+	FormatYAML      = display.FormatYAML
+	FormatEnv       = display.FormatEnv
+	FormatTerraform = display.FormatTerraform
+	FormatDOT       = display.FormatDOT
+	FormatMermaid   = display.FormatMermaid
+)
+
+// Formats lists every supported output format.
+var Formats = display.Formats
+
+// ParseFormat returns the Format named by s.
+func ParseFormat(s string) (Format, error) {
+	return display.ParseFormat(s)
+}
+
+// Encoder writes groups, projects and hierarchies one at a time in a
+// Format. Close must be called to finish the output.
+type Encoder = display.Encoder
+
+// EncoderOption configures optional behaviour of an Encoder.
+type EncoderOption = display.EncoderOption
+
+// NewEncoder returns an Encoder writing format to w.
+func NewEncoder(w io.Writer, format Format, opts ...EncoderOption) (Encoder, error) {
+	return display.NewEncoder(w, format, opts...)
+}
+
+// WithLinks adds links to each item's GitLab page, for formats that support them.
+func WithLinks(enabled bool) EncoderOption {
+	return display.WithLinks(enabled)
+}
+
+// DefaultEnvPrefix is the variable name prefix used by FormatEnv.
+const DefaultEnvPrefix = display.DefaultEnvPrefix
+
+// WithEnvPrefix sets the prefix of variable names written by FormatEnv.
+func WithEnvPrefix(prefix string) EncoderOption {
+	return display.WithEnvPrefix(prefix)
+}
+
+// Metadata describes how a set of results was produced.
+type Metadata = display.Metadata
+
+// WithMetadata records meta in the output, for formats that can carry it.
+func WithMetadata(meta *Metadata) EncoderOption {
+	return display.WithMetadata(meta)
+}
+
+// WithTextOptions passes print options to FormatText lines and trees.
+func WithTextOptions(opts ...PrintOption) EncoderOption {
+	return display.WithTextOptions(opts...)
+}
+
+// Record is the flat representation of a group or project used by the
+// machine-readable formats.
+type Record = display.Record
+
+// TreeRecord is a group record with its populated children.
+type TreeRecord = display.TreeRecord
+
+// GroupRecord converts a group to its flat record.
+func GroupRecord(g Group) Record {
+	return display.GroupRecord(g)
+}
+
+// ProjectRecord converts a project to its flat record.
+func ProjectRecord(p Project) Record {
+	return display.ProjectRecord(p)
+}
+
+// NewTreeRecord converts a populated group and its descendants to a tree record.
+func NewTreeRecord(g Group) TreeRecord {
+	return display.NewTreeRecord(g)
+}
+
+// Report is the content of an HTML report.
+type Report = display.Report
+
+// WriteHTMLReport writes r to w as a self-contained HTML page.
+func WriteHTMLReport(w io.Writer, r Report) error {
+	return display.WriteHTMLReport(w, r)
+}
+
+// Lang is a language WriteConstants can generate.
+type Lang = display.Lang
+
+// Languages WriteConstants can generate.
+const (
+	LangGo         = display.LangGo
+	LangTypeScript = display.LangTypeScript
+	LangPython     = display.LangPython
+)
+
+// Constants is the content of a generated source file of ID constants.
+type Constants = display.Constants
+
+// WriteConstants writes c to w as source code in c.Lang.
+func WriteConstants(w io.Writer, c Constants) error {
+	return display.WriteConstants(w, c)
+}
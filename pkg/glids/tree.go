@@ -0,0 +1,82 @@
+package glids
+
+import "github.com/bboles/glids/internal/gitlab"
+
+// HierarchyOptions limits what Client.PopulateHierarchy fetches.
+type HierarchyOptions = gitlab.HierarchyOptions
+
+// PruneOptions selects which branches PruneHierarchy keeps.
+type PruneOptions = gitlab.PruneOptions
+
+// PruneHierarchy removes branches from a populated group in place and
+// reports whether anything was kept beneath it.
+func PruneHierarchy(group *Group, opts PruneOptions) bool {
+	return gitlab.PruneHierarchy(group, opts)
+}
+
+// Flatten returns every group and project in a populated hierarchy,
+// depth-first, starting with group itself.
+func Flatten(group Group) ([]Group, []Project) {
+	return gitlab.Flatten(group)
+}
+
+// GroupStats summarises a populated group.
+type GroupStats = gitlab.GroupStats
+
+// ComputeStats summarises a populated group and its descendants.
+func ComputeStats(group Group) GroupStats {
+	return gitlab.ComputeStats(group)
+}
+
+// SortKey names an order for groups and projects.
+type SortKey = gitlab.SortKey
+
+// Sort keys.
+const (
+	SortPath     = gitlab.SortPath
+	SortName     = gitlab.SortName
+	SortID       = gitlab.SortID
+	SortActivity = gitlab.SortActivity
+	SortCreated  = gitlab.SortCreated
+	SortSize     = gitlab.SortSize
+)
+
+// SortKeys lists every sort key.
+var SortKeys = gitlab.SortKeys
+
+// ParseSortKey returns the SortKey named by s.
+func ParseSortKey(s string) (SortKey, error) {
+	return gitlab.ParseSortKey(s)
+}
+
+// SortGroups sorts groups in place by key, reversed if reverse is set.
+func SortGroups(groups []Group, key SortKey, reverse bool) {
+	gitlab.SortGroups(groups, key, reverse)
+}
+
+// SortProjects sorts projects in place by key, reversed if reverse is set.
+func SortProjects(projects []Project, key SortKey, reverse bool) {
+	gitlab.SortProjects(projects, key, reverse)
+}
+
+// SortHierarchy sorts every level of a populated group in place.
+func SortHierarchy(group *Group, key SortKey, reverse bool) {
+	gitlab.SortHierarchy(group, key, reverse)
+}
+
+// ComparePaths compares full paths in SortPath order, ignoring case.
+func ComparePaths(a, b string) int {
+	return gitlab.ComparePaths(a, b)
+}
+
+// ProjectsInOrder reports whether Client.ProjectsBy yields projects already
+// in key's order, so they can be used as they arrive.
+func ProjectsInOrder(key SortKey) bool {
+	return gitlab.ProjectsInOrder(key)
+}
+
+// GroupsInOrder reports whether Client.GroupsBy yields groups already in
+// key's order.
+func GroupsInOrder(key SortKey) bool {
+	return gitlab.GroupsInOrder(key)
+}